@@ -0,0 +1,168 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"strconv"
+	"sync"
+)
+
+// APIError is a normalized view of an error response body in a well-known
+// format, as returned by Error.API().
+type APIError struct {
+	// Code is the machine readable error code or type, if any.
+	Code string
+	// Message is the human readable error message.
+	Message string
+	// Details is the vendor specific additional information, if any.
+	Details json.RawMessage
+}
+
+// Error implements error, returning "<code>: <message>".
+func (a *APIError) Error() string {
+	if a.Code == "" {
+		return a.Message
+	}
+	return a.Code + ": " + a.Message
+}
+
+// ErrorDecoder recognizes a vendor specific error body. It returns nil if b
+// is not in the expected format.
+type ErrorDecoder func(b []byte) *APIError
+
+var (
+	errorDecodersMu sync.Mutex
+	errorDecoders   = []ErrorDecoder{decodeGoogleError, decodeStripeError, decodeProblemError, decodeGitHubError}
+)
+
+// RegisterErrorDecoder adds a decoder used by Error.API(). Decoders
+// registered later are tried first, before the built-in ones for Google
+// APIs, Stripe, RFC 9457 problem details and GitHub.
+func RegisterErrorDecoder(d ErrorDecoder) {
+	errorDecodersMu.Lock()
+	defer errorDecodersMu.Unlock()
+	errorDecoders = append([]ErrorDecoder{d}, errorDecoders...)
+}
+
+// API returns the response body decoded by the first ErrorDecoder that
+// recognizes it, or nil. The raw body is still available in ResponseBody.
+func (h *Error) API() *APIError {
+	if len(h.ResponseBody) == 0 {
+		return nil
+	}
+	errorDecodersMu.Lock()
+	decoders := errorDecoders
+	errorDecodersMu.Unlock()
+	for _, d := range decoders {
+		if a := d(h.ResponseBody); a != nil {
+			return a
+		}
+	}
+	return nil
+}
+
+// DecodeXMLError decodes XML error documents with Code and Message elements,
+// like the ones returned by S3 compatible storage or some gateways in front
+// of JSON APIs:
+//
+//	<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>
+//
+// It is not enabled by default; use RegisterErrorDecoder(DecodeXMLError).
+// When enabled, a response that fails to decode as JSON but is a recognized
+// XML error returns the *APIError instead of the JSON syntax error.
+func DecodeXMLError(b []byte) *APIError {
+	var v struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if len(bytes.TrimSpace(b)) == 0 || bytes.TrimSpace(b)[0] != '<' {
+		return nil
+	}
+	if xml.Unmarshal(b, &v) != nil || (v.Code == "" && v.Message == "") {
+		return nil
+	}
+	return &APIError{Code: v.Code, Message: v.Message}
+}
+
+// decodeGoogleError decodes https://google.aip.dev/193 errors:
+// {"error":{"code":404,"message":"...","status":"NOT_FOUND","details":[...]}}
+func decodeGoogleError(b []byte) *APIError {
+	var v struct {
+		Error *struct {
+			Code    *int            `json:"code"`
+			Message string          `json:"message"`
+			Status  string          `json:"status"`
+			Details json.RawMessage `json:"details"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(b, &v) != nil || v.Error == nil || v.Error.Code == nil {
+		return nil
+	}
+	a := &APIError{Code: v.Error.Status, Message: v.Error.Message, Details: v.Error.Details}
+	if a.Code == "" {
+		a.Code = strconv.Itoa(*v.Error.Code)
+	}
+	return a
+}
+
+// decodeStripeError decodes https://docs.stripe.com/api/errors:
+// {"error":{"type":"card_error","code":"card_declined","message":"..."}}
+func decodeStripeError(b []byte) *APIError {
+	var v struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if json.Unmarshal(b, &v) != nil || len(v.Error) == 0 {
+		return nil
+	}
+	var e struct {
+		Type    string `json:"type"`
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(v.Error, &e) != nil || e.Type == "" {
+		return nil
+	}
+	a := &APIError{Code: e.Code, Message: e.Message, Details: v.Error}
+	if a.Code == "" {
+		a.Code = e.Type
+	}
+	return a
+}
+
+// decodeProblemError decodes RFC 9457 problem details:
+// {"type":"https://...","title":"...","status":403,"detail":"..."}
+func decodeProblemError(b []byte) *APIError {
+	var v struct {
+		Type   string `json:"type"`
+		Title  string `json:"title"`
+		Status int    `json:"status"`
+		Detail string `json:"detail"`
+	}
+	if json.Unmarshal(b, &v) != nil || (v.Title == "" && v.Detail == "") || (v.Type == "" && v.Status == 0) {
+		return nil
+	}
+	a := &APIError{Code: v.Type, Message: v.Detail, Details: b}
+	if a.Message == "" {
+		a.Message = v.Title
+	}
+	return a
+}
+
+// decodeGitHubError decodes
+// https://docs.github.com/rest/using-the-rest-api/troubleshooting-the-rest-api
+// errors: {"message":"...","errors":[...],"documentation_url":"..."}
+func decodeGitHubError(b []byte) *APIError {
+	var v struct {
+		Message string          `json:"message"`
+		Errors  json.RawMessage `json:"errors"`
+	}
+	if json.Unmarshal(b, &v) != nil || v.Message == "" {
+		return nil
+	}
+	return &APIError{Message: v.Message, Details: v.Errors}
+}
@@ -0,0 +1,114 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestError_API(t *testing.T) {
+	t.Parallel()
+	data := []struct {
+		name string
+		body string
+		want *APIError
+	}{
+		{
+			"google",
+			`{"error":{"code":404,"message":"not here","status":"NOT_FOUND","details":[{"a":1}]}}`,
+			&APIError{Code: "NOT_FOUND", Message: "not here", Details: []byte(`[{"a":1}]`)},
+		},
+		{
+			"google_no_status",
+			`{"error":{"code":400,"message":"bad"}}`,
+			&APIError{Code: "400", Message: "bad"},
+		},
+		{
+			"stripe",
+			`{"error":{"type":"card_error","code":"card_declined","message":"declined"}}`,
+			&APIError{Code: "card_declined", Message: "declined", Details: []byte(`{"type":"card_error","code":"card_declined","message":"declined"}`)},
+		},
+		{
+			"problem",
+			`{"type":"https://example.com/out-of-credit","title":"Out of credit","status":403,"detail":"Balance is 30"}`,
+			&APIError{Code: "https://example.com/out-of-credit", Message: "Balance is 30", Details: []byte(`{"type":"https://example.com/out-of-credit","title":"Out of credit","status":403,"detail":"Balance is 30"}`)},
+		},
+		{
+			"github",
+			`{"message":"Validation Failed","errors":[{"code":"missing"}]}`,
+			&APIError{Message: "Validation Failed", Details: []byte(`[{"code":"missing"}]`)},
+		},
+		{"unknown", `{"foo":"bar"}`, nil},
+		{"not_json", `<html></html>`, nil},
+		{"empty", ``, nil},
+	}
+	for _, line := range data {
+		t.Run(line.name, func(t *testing.T) {
+			t.Parallel()
+			got := (&Error{ResponseBody: []byte(line.body)}).API()
+			if (got == nil) != (line.want == nil) {
+				t.Fatalf("Unexpected\nwant: %+v\ngot:  %+v", line.want, got)
+			}
+			if got == nil {
+				return
+			}
+			if got.Code != line.want.Code || got.Message != line.want.Message || string(got.Details) != string(line.want.Details) {
+				t.Errorf("Unexpected\nwant: %+v\ngot:  %+v", line.want, got)
+			}
+		})
+	}
+}
+
+func TestRegisterErrorDecoder(t *testing.T) {
+	t.Parallel()
+	RegisterErrorDecoder(func(b []byte) *APIError {
+		if string(b) != `{"message":"custom decoder"}` {
+			return nil
+		}
+		return &APIError{Code: "custom"}
+	})
+	got := (&Error{ResponseBody: []byte(`{"message":"custom decoder"}`)}).API()
+	if got == nil || got.Code != "custom" {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %+v", "custom", got)
+	}
+}
+
+func TestDecodeXMLError(t *testing.T) {
+	t.Parallel()
+	RegisterErrorDecoder(DecodeXMLError)
+	const body = `<?xml version="1.0" encoding="UTF-8"?>
+<Error><Code>AccessDenied</Code><Message>Access Denied</Message><RequestId>1</RequestId></Error>`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(body))
+	}))
+	defer ts.Close()
+	var out struct{}
+	err := (&Client{}).Get(context.Background(), ts.URL, nil, &out)
+	var api *APIError
+	if !errors.As(err, &api) {
+		t.Fatalf("expected APIError, got %v", err)
+	}
+	if api.Code != "AccessDenied" || api.Message != "Access Denied" {
+		t.Errorf("Unexpected %+v", api)
+	}
+	var herr *Error
+	if !errors.As(err, &herr) || herr.StatusCode != http.StatusForbidden {
+		t.Errorf("expected Error, got %v", err)
+	}
+	if want := "AccessDenied: Access Denied\nGET " + ts.URL + ": http 403\n" + body; err.Error() != want {
+		t.Errorf("Unexpected\nwant: %q\ngot:  %q", want, err.Error())
+	}
+	for _, b := range []string{`{"Code":"x"}`, `<html><body>nope</body></html>`, ``} {
+		if a := DecodeXMLError([]byte(b)); a != nil {
+			t.Errorf("%q: Unexpected %+v", b, a)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrHostsUnavailable is returned by Balancer when the circuits of all the
+// hosts are open.
+var ErrHostsUnavailable = errors.New("all hosts are unavailable")
+
+// Balancer is an http.RoundTripper spreading the requests across equivalent
+// hosts, e.g. the nodes of a self-hosted API cluster, without an external
+// load balancer.
+//
+// Each host has a circuit breaker: after MaxFailures consecutive transport
+// errors or 5xx statuses, the host is skipped for Cooldown. Then a single
+// request is let through, closing the circuit if it succeeds.
+//
+// A Balancer must not be copied after first use.
+type Balancer struct {
+	// Transport sends the requests. Defaults to http.DefaultTransport.
+	Transport http.RoundTripper
+	// Hosts are the "host" or "host:port" replacing the host of the request
+	// URL. The Host header is not changed if it was set.
+	Hosts []string
+	// LeastInflight sends each request to the host with the fewest requests
+	// in flight, including the ones whose response body is still being read,
+	// instead of round robin.
+	LeastInflight bool
+	// MaxFailures is the number of consecutive failures opening the circuit
+	// of a host. Defaults to 5.
+	MaxFailures int
+	// Cooldown is how long a circuit stays open. Defaults to 30 seconds.
+	Cooldown time.Duration
+	// Clock is used to expire the cooldowns. Defaults to the real time.
+	Clock Clock
+
+	mu    sync.Mutex
+	next  int
+	hosts map[string]*hostState
+}
+
+// hostState is the circuit breaker of a host.
+type hostState struct {
+	inflight  int
+	failures  int
+	openUntil time.Time
+	// probing is set while the single request of a half-open circuit is in
+	// flight.
+	probing bool
+}
+
+// RoundTrip implements http.RoundTripper.
+func (b *Balancer) RoundTrip(req *http.Request) (*http.Response, error) {
+	host, s := b.pick()
+	if s == nil {
+		closeBody(req)
+		return nil, ErrHostsUnavailable
+	}
+	r := req.Clone(req.Context())
+	r.URL.Host = host
+	resp, err := transportOr(b.Transport).RoundTrip(r)
+	if err != nil && req.Context().Err() != nil {
+		// The caller gave up so the health of the host is unknown.
+		b.abandon(s)
+		b.release(s)
+		return nil, err
+	}
+	b.record(s, err != nil || resp.StatusCode >= 500)
+	if err != nil {
+		b.release(s)
+		return nil, err
+	}
+	resp.Body = &releaseCloser{ReadCloser: resp.Body, release: func() { b.release(s) }}
+	return resp, nil
+}
+
+// pick returns the host to use and marks it as in flight.
+func (b *Balancer) pick() (string, *hostState) {
+	now := clockOr(b.Clock).Now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.hosts == nil {
+		b.hosts = map[string]*hostState{}
+	}
+	best := -1
+	var bestState *hostState
+	for i := range b.Hosts {
+		idx := (b.next + i) % len(b.Hosts)
+		s := b.hosts[b.Hosts[idx]]
+		if s == nil {
+			s = &hostState{}
+			b.hosts[b.Hosts[idx]] = s
+		}
+		if s.probing || now.Before(s.openUntil) {
+			continue
+		}
+		if best == -1 || (b.LeastInflight && s.inflight < bestState.inflight) {
+			best, bestState = idx, s
+			if !b.LeastInflight {
+				break
+			}
+		}
+	}
+	if best == -1 {
+		return "", nil
+	}
+	b.next = best + 1
+	bestState.inflight++
+	if !bestState.openUntil.IsZero() {
+		// Half-open: let this request through alone.
+		bestState.probing = true
+	}
+	return b.Hosts[best], bestState
+}
+
+// record updates the circuit of s with the result of a request.
+func (b *Balancer) record(s *hostState, failed bool) {
+	now := clockOr(b.Clock).Now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	wasProbing := s.probing
+	s.probing = false
+	if !failed {
+		s.failures = 0
+		s.openUntil = time.Time{}
+		return
+	}
+	s.failures++
+	maxFailures := b.MaxFailures
+	if maxFailures <= 0 {
+		maxFailures = defaultMaxFailures
+	}
+	if wasProbing || s.failures >= maxFailures {
+		cooldown := b.Cooldown
+		if cooldown <= 0 {
+			cooldown = defaultCooldown
+		}
+		s.openUntil = now.Add(cooldown)
+	}
+}
+
+// abandon lets another request probe s without changing its circuit.
+func (b *Balancer) abandon(s *hostState) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s.probing = false
+}
+
+func (b *Balancer) release(s *hostState) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s.inflight--
+}
+
+// defaultMaxFailures is used when Balancer.MaxFailures is not set.
+const defaultMaxFailures = 5
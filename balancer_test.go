@@ -0,0 +1,177 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newBalancerServer returns a server replying with its name, and its
+// "host:port".
+func newBalancerServer(t *testing.T, name string, down *atomic.Bool) string {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if down != nil && down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_, _ = w.Write([]byte(`{"from":"` + name + `"}`))
+	}))
+	t.Cleanup(ts.Close)
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.Host
+}
+
+func balancerGet(t *testing.T, c *Client) (string, error) {
+	t.Helper()
+	var out struct {
+		From string `json:"from"`
+	}
+	resp, err := c.GetRequest(context.Background(), "http://cluster.local/", nil)
+	if err != nil {
+		return "", err
+	}
+	if err = c.decodeResponse(resp, &out); err != nil {
+		return "", err
+	}
+	if resp.StatusCode >= 500 {
+		return out.From, errors.New(resp.Status)
+	}
+	return out.From, nil
+}
+
+func TestBalancer(t *testing.T) {
+	t.Parallel()
+	var bDown atomic.Bool
+	a := newBalancerServer(t, "a", nil)
+	b := newBalancerServer(t, "b", &bDown)
+	clk := &fakeClock{now: time.Unix(1000, 0)}
+	lb := &Balancer{Hosts: []string{a, b}, MaxFailures: 2, Cooldown: time.Minute, Clock: clk}
+	c := &Client{Client: &http.Client{Transport: lb}}
+	check := func(want string, wantErr bool) {
+		t.Helper()
+		got, err := balancerGet(t, c)
+		if (err != nil) != wantErr || got != want {
+			t.Fatalf("Unexpected\nwant: %v, %t\ngot:  %v, %v", want, wantErr, got, err)
+		}
+	}
+	// Round robin.
+	check("a", false)
+	check("b", false)
+	check("a", false)
+	check("b", false)
+	// The circuit of b opens after 2 consecutive failures.
+	bDown.Store(true)
+	check("a", false)
+	check("b", true)
+	check("a", false)
+	check("b", true)
+	check("a", false)
+	check("a", false)
+	// Half-open: a single failure opens it again.
+	clk.Advance(time.Minute)
+	check("b", true)
+	check("a", false)
+	check("a", false)
+	// Recovered.
+	bDown.Store(false)
+	clk.Advance(time.Minute)
+	check("b", false)
+	check("a", false)
+	check("b", false)
+}
+
+func TestBalancer_unavailable(t *testing.T) {
+	t.Parallel()
+	var down atomic.Bool
+	down.Store(true)
+	a := newBalancerServer(t, "a", &down)
+	lb := &Balancer{Hosts: []string{a}, MaxFailures: 1}
+	c := &Client{Client: &http.Client{Transport: lb}}
+	if _, err := balancerGet(t, c); err == nil {
+		t.Fatal("expected error")
+	}
+	if _, err := balancerGet(t, c); !errors.Is(err, ErrHostsUnavailable) {
+		t.Fatalf("Unexpected error %v", err)
+	}
+}
+
+func TestBalancer_leastInflight(t *testing.T) {
+	t.Parallel()
+	a := newBalancerServer(t, "a", nil)
+	b := newBalancerServer(t, "b", nil)
+	lb := &Balancer{Hosts: []string{a, b}, LeastInflight: true}
+	c := &Client{Client: &http.Client{Transport: lb}}
+	// Keep a request to a in flight by not closing its body.
+	resp, err := c.GetRequest(context.Background(), "http://cluster.local/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		if got, err := balancerGet(t, c); err != nil || got != "b" {
+			t.Fatalf("Unexpected %q, %v", got, err)
+		}
+	}
+	if err = resp.Body.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := balancerGet(t, c); err != nil || got != "a" {
+		t.Fatalf("Unexpected %q, %v", got, err)
+	}
+}
+
+func TestBalancer_canceled(t *testing.T) {
+	t.Parallel()
+	var calls atomic.Int32
+	tr := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		calls.Add(1)
+		if err := r.Context().Err(); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("connection refused")
+	})
+	clk := &fakeClock{now: time.Unix(1000, 0)}
+	lb := &Balancer{Transport: tr, Hosts: []string{"a"}, MaxFailures: 2, Cooldown: time.Minute, Clock: clk}
+	do := func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, "GET", "http://cluster.local/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = lb.RoundTrip(req)
+		return err
+	}
+	for range 2 {
+		if err := do(context.Background()); err == nil || errors.Is(err, ErrHostsUnavailable) {
+			t.Fatalf("Unexpected error %v", err)
+		}
+	}
+	// The half-open probe is canceled; the circuit stays open.
+	clk.Advance(time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := do(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if err := do(context.Background()); err == nil || errors.Is(err, ErrHostsUnavailable) {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	// That was still a half-open probe, so a single failure opened the circuit
+	// again.
+	if err := do(context.Background()); !errors.Is(err, ErrHostsUnavailable) {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if n := calls.Load(); n != 4 {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", 4, n)
+	}
+}
@@ -0,0 +1,318 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maruel/httpjson/cache"
+)
+
+// Cache memoizes GET response bodies keyed by URL and request headers,
+// keeping them for TTL in a cache.Store.
+//
+// Set it as Client.Cache. It is safe for concurrent use.
+type Cache struct {
+	// StaleWhileRevalidate is how long past TTL an entry is still returned
+	// while it is refreshed in the background.
+	StaleWhileRevalidate time.Duration
+	// RevalidateTimeout bounds the background refresh done during
+	// StaleWhileRevalidate, so a hung server doesn't prevent further
+	// refreshes of the entry. Defaults to 30 seconds.
+	RevalidateTimeout time.Duration
+	// StaleIfError is how long past TTL an entry is returned when refreshing
+	// it fails with a transport error or a 5xx status.
+	StaleIfError time.Duration
+	// Clock is used to expire entries. Defaults to the real time.
+	Clock Clock
+
+	store cache.Store
+	ttl   time.Duration
+
+	mu         sync.Mutex
+	refreshing map[string]struct{}
+}
+
+// NewCache returns a Cache keeping entries for ttl in store.
+//
+// Errors from store are ignored: a failed Get is a miss and a failed Set
+// isn't cached.
+func NewCache(store cache.Store, ttl time.Duration) *Cache {
+	return &Cache{store: store, ttl: ttl, refreshing: map[string]struct{}{}}
+}
+
+// NewMemoryCache returns a Cache keeping entries for ttl in memory.
+// maxEntries of 0 means no limit.
+func NewMemoryCache(ttl time.Duration, maxEntries int) *Cache {
+	return NewCache(cache.NewMemory(maxEntries), ttl)
+}
+
+// Freshness describes how fresh the value decoded by a Get call served through
+// Client.Cache is.
+type Freshness struct {
+	// Hit is true when the value was decoded from a cached body instead of a
+	// response from the server.
+	Hit bool
+	// Age is how long ago the cached body was fetched. It is 0 when Hit is
+	// false.
+	Age time.Duration
+	// Stale is true when the cached body is past its TTL: it is served while
+	// being refreshed in the background, or because refreshing it failed.
+	Stale bool
+	// Revalidated is true when an expired cached body was replaced by a new
+	// response from the server.
+	Revalidated bool
+}
+
+type freshnessKey struct{}
+
+// WithFreshness returns a context for Get calls that records in f how fresh
+// the decoded value is, so callers can decide whether to show or trust
+// possibly stale data.
+//
+// f is only updated for Get calls on a Client with a Cache.
+func WithFreshness(ctx context.Context, f *Freshness) context.Context {
+	return context.WithValue(ctx, freshnessKey{}, f)
+}
+
+// cacheState is the result of a Cache lookup.
+type cacheState int
+
+const (
+	cacheMiss cacheState = iota
+	// cacheFresh means the body can be used as is.
+	cacheFresh
+	// cacheStale means the body can be used but the caller must refresh it.
+	cacheStale
+	// cacheStaleIfError means the body can only be used if refreshing fails.
+	cacheStaleIfError
+)
+
+// cachedResponse is a response body kept in the Cache.
+type cachedResponse struct {
+	contentType string
+	body        []byte
+}
+
+// The stored values are the expiration time in Unix nanoseconds as 8 bytes
+// big endian, the Content-Type length as 2 bytes big endian, the Content-Type
+// and the body, so the stale windows work with any Store.
+
+func encodeCached(expires time.Time, r cachedResponse) []byte {
+	ct := r.contentType[:min(len(r.contentType), math.MaxUint16)]
+	v := make([]byte, 0, 10+len(ct)+len(r.body))
+	v = binary.BigEndian.AppendUint64(v, uint64(expires.UnixNano()))
+	v = binary.BigEndian.AppendUint16(v, uint16(len(ct)))
+	v = append(v, ct...)
+	return append(v, r.body...)
+}
+
+func decodeCached(v []byte) (time.Time, cachedResponse, bool) {
+	if len(v) < 10 {
+		return time.Time{}, cachedResponse{}, false
+	}
+	expires := time.Unix(0, int64(binary.BigEndian.Uint64(v)))
+	n := int(binary.BigEndian.Uint16(v[8:]))
+	if len(v) < 10+n {
+		return time.Time{}, cachedResponse{}, false
+	}
+	return expires, cachedResponse{contentType: string(v[10 : 10+n]), body: v[10+n:]}, true
+}
+
+// storeKey returns the key used in the Store. The key contains the request
+// headers, including credentials like Authorization, so it is hashed.
+func storeKey(key string) string {
+	h := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(h[:])
+}
+
+// get returns the entry for key, its state and how long ago it was fetched.
+func (m *Cache) get(ctx context.Context, key string) (cachedResponse, cacheState, time.Duration) {
+	key = storeKey(key)
+	v, ok, err := m.store.Get(ctx, key)
+	if !ok || err != nil {
+		return cachedResponse{}, cacheMiss, 0
+	}
+	expires, r, ok := decodeCached(v)
+	if !ok {
+		return cachedResponse{}, cacheMiss, 0
+	}
+	now := clockOr(m.Clock).Now()
+	expired := now.Sub(expires)
+	age := expired + m.ttl
+	if expired < 0 {
+		return r, cacheFresh, age
+	}
+	switch {
+	case expired < m.StaleWhileRevalidate:
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if _, ok := m.refreshing[key]; ok {
+			// Only one caller refreshes; the others keep using the stale body
+			// meanwhile.
+			return r, cacheFresh, age
+		}
+		m.refreshing[key] = struct{}{}
+		return r, cacheStale, age
+	case expired < m.StaleIfError:
+		return r, cacheStaleIfError, age
+	default:
+		_ = m.store.Delete(ctx, key)
+		return cachedResponse{}, cacheMiss, 0
+	}
+}
+
+// refreshFailed allows another caller to refresh the entry.
+func (m *Cache) refreshFailed(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.refreshing, storeKey(key))
+}
+
+func (m *Cache) set(ctx context.Context, key string, resp *http.Response, body []byte) {
+	v := encodeCached(clockOr(m.Clock).Now().Add(m.ttl), cachedResponse{contentType: resp.Header.Get("Content-Type"), body: body})
+	_ = m.store.Set(ctx, storeKey(key), v, m.ttl+max(m.StaleWhileRevalidate, m.StaleIfError))
+	m.refreshFailed(key)
+}
+
+// cacheKey returns the key for a GET of url with headers hdr. Header lines
+// are canonicalized and sorted so the key is stable.
+func cacheKey(url string, hdr http.Header) string {
+	var lines []string
+	for k, vs := range hdr {
+		k = http.CanonicalHeaderKey(k)
+		for _, v := range vs {
+			lines = append(lines, k+": "+v)
+		}
+	}
+	slices.Sort(lines)
+	return url + "\n" + strings.Join(lines, "\n")
+}
+
+// cachedGet implements Get when c.Cache is set. Only 2xx responses that
+// decode successfully are cached.
+func (c *Client) cachedGet(ctx context.Context, url string, hdr http.Header, out any) error {
+	key := cacheKey(url, hdr)
+	stale, state, age := c.Cache.get(ctx, key)
+	f, _ := ctx.Value(freshnessKey{}).(*Freshness)
+	if f == nil {
+		f = &Freshness{}
+	}
+	switch state {
+	case cacheFresh:
+		*f = Freshness{Hit: true, Age: age, Stale: age >= c.Cache.ttl}
+		return c.decodeCached(ctx, url, hdr, stale, out)
+	case cacheStale:
+		*f = Freshness{Hit: true, Age: age, Stale: true}
+		go c.revalidate(ctx, key, url, hdr, reflect.TypeOf(out))
+		return c.decodeCached(ctx, url, hdr, stale, out)
+	default:
+	}
+	resp, b, err := c.fetch(ctx, url, hdr)
+	if state == cacheStaleIfError {
+		if err != nil || resp.StatusCode >= 500 {
+			*f = Freshness{Hit: true, Age: age, Stale: true}
+			return c.decodeCached(ctx, url, hdr, stale, out)
+		}
+		*f = Freshness{Revalidated: true}
+	} else {
+		*f = Freshness{}
+	}
+	if err != nil {
+		return err
+	}
+	if err = c.decodeBody(resp, b, out); err == nil && isSuccess(resp) {
+		c.Cache.set(ctx, key, resp, b)
+	}
+	return err
+}
+
+// decodeCached decodes a cached body like a response received from the
+// network, so the errors are the same.
+func (c *Client) decodeCached(ctx context.Context, url string, hdr http.Header, r cachedResponse, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	if hdr != nil {
+		req.Header = hdr.Clone()
+	}
+	resp := &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{},
+		Request:    req,
+	}
+	if r.contentType != "" {
+		resp.Header.Set("Content-Type", r.contentType)
+	}
+	return c.decodeBody(resp, r.body, out)
+}
+
+// revalidate refreshes a stale cache entry in the background. t is the type
+// of the output passed to Get, used to verify the new body decodes.
+//
+// It is not canceled with ctx, since the caller already returned, but is
+// bounded by Cache.RevalidateTimeout.
+func (c *Client) revalidate(ctx context.Context, key, url string, hdr http.Header, t reflect.Type) {
+	timeout := c.Cache.RevalidateTimeout
+	if timeout <= 0 {
+		timeout = defaultRevalidateTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+	resp, b, err := c.fetch(ctx, url, hdr)
+	if err == nil && isSuccess(resp) {
+		var v any = new(any)
+		if t != nil && t.Kind() == reflect.Pointer {
+			v = reflect.New(t.Elem()).Interface()
+		}
+		if err = c.decodeBody(resp, b, v); err == nil {
+			c.Cache.set(ctx, key, resp, b)
+			return
+		}
+	}
+	c.Cache.refreshFailed(key)
+}
+
+// defaultRevalidateTimeout is used when Cache.RevalidateTimeout is not set.
+const defaultRevalidateTimeout = 30 * time.Second
+
+// fetch does a GET and reads the response body, sharing the request with
+// concurrent callers if c.Coalesce is set.
+func (c *Client) fetch(ctx context.Context, url string, hdr http.Header) (*http.Response, []byte, error) {
+	if c.Coalesce {
+		return c.coalesce(cacheKey(url, hdr), func() (*http.Response, []byte, error) {
+			return c.fetchOnce(ctx, url, hdr)
+		})
+	}
+	return c.fetchOnce(ctx, url, hdr)
+}
+
+func (c *Client) fetchOnce(ctx context.Context, url string, hdr http.Header) (*http.Response, []byte, error) {
+	resp, err := c.GetRequest(ctx, url, hdr)
+	if err != nil {
+		return nil, nil, err
+	}
+	_, b, err := readJSONBody(resp)
+	return resp, b, err
+}
+
+func isSuccess(resp *http.Response) bool {
+	return resp.StatusCode >= 200 && resp.StatusCode < 300
+}
@@ -0,0 +1,129 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package cache defines the storage used by httpjson.Cache and ships an
+// in-memory and a filesystem implementation.
+//
+// Store is kept small so a shared cache like Redis or groupcache can be
+// plugged in with a few lines, see the example.
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// Store is a key-value store with expiration.
+//
+// Implementations must be safe for concurrent use. The values passed to Set
+// and returned by Get must not be modified.
+//
+// httpjson.Cache uses hex encoded SHA-256 hashes as keys, so the URLs and the
+// request headers, which may contain credentials, are not stored in clear.
+type Store interface {
+	// Get returns the value for key, or false if it is missing or expired.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores value for key, expiring after ttl. A ttl of 0 means no
+	// expiration.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete removes key. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// Memory is an in-memory Store evicting the least recently used entries
+// beyond its capacity.
+type Memory struct {
+	maxEntries int
+	now        func() time.Time
+
+	mu    sync.Mutex
+	lru   *list.List
+	items map[string]*list.Element
+}
+
+// NewMemory returns a Memory keeping at most maxEntries. 0 means no limit.
+func NewMemory(maxEntries int) *Memory {
+	return &Memory{
+		maxEntries: maxEntries,
+		now:        time.Now,
+		lru:        list.New(),
+		items:      map[string]*list.Element{},
+	}
+}
+
+type memoryEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// Get implements Store.
+func (m *Memory) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+	ent := e.Value.(*memoryEntry)
+	if !ent.expires.IsZero() && !m.now().Before(ent.expires) {
+		m.lru.Remove(e)
+		delete(m.items, key)
+		return nil, false, nil
+	}
+	m.lru.MoveToFront(e)
+	return ent.value, true, nil
+}
+
+// Set implements Store.
+func (m *Memory) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var expires time.Time
+	if ttl > 0 {
+		expires = m.now().Add(ttl)
+	}
+	if e, ok := m.items[key]; ok {
+		ent := e.Value.(*memoryEntry)
+		ent.value = value
+		ent.expires = expires
+		m.lru.MoveToFront(e)
+		return nil
+	}
+	m.items[key] = m.lru.PushFront(&memoryEntry{key: key, value: value, expires: expires})
+	for m.maxEntries > 0 && m.lru.Len() > m.maxEntries {
+		e := m.lru.Back()
+		m.lru.Remove(e)
+		delete(m.items, e.Value.(*memoryEntry).key)
+	}
+	return nil
+}
+
+// Delete implements Store.
+func (m *Memory) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, ok := m.items[key]; ok {
+		m.lru.Remove(e)
+		delete(m.items, key)
+	}
+	return nil
+}
+
+// Len returns the number of entries, including expired ones not yet evicted.
+func (m *Memory) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lru.Len()
+}
+
+// Purge removes all the entries.
+func (m *Memory) Purge() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lru.Init()
+	clear(m.items)
+}
@@ -0,0 +1,101 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMemory(t *testing.T) {
+	t.Parallel()
+	now := time.Unix(1000, 0)
+	m := NewMemory(2)
+	m.now = func() time.Time { return now }
+	testStore(t, m, func(d time.Duration) { now = now.Add(d) })
+	// Eviction of the least recently used entry.
+	ctx := context.Background()
+	_ = m.Set(ctx, "a", []byte("1"), 0)
+	_ = m.Set(ctx, "b", []byte("2"), 0)
+	_, _, _ = m.Get(ctx, "a")
+	_ = m.Set(ctx, "c", []byte("3"), 0)
+	if _, ok, _ := m.Get(ctx, "b"); ok {
+		t.Error("expected b to be evicted")
+	}
+	if _, ok, _ := m.Get(ctx, "a"); !ok {
+		t.Error("expected a to be kept")
+	}
+	if l := m.Len(); l != 2 {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", 2, l)
+	}
+	m.Purge()
+	if l := m.Len(); l != 0 {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", 0, l)
+	}
+}
+
+func TestDir(t *testing.T) {
+	t.Parallel()
+	now := time.Unix(1000, 0)
+	d := NewDir(t.TempDir())
+	d.now = func() time.Time { return now }
+	testStore(t, d, func(delta time.Duration) { now = now.Add(delta) })
+	// Corrupted files are ignored.
+	if err := os.WriteFile(d.path("x"), []byte("bad"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := d.Get(context.Background(), "x"); ok || err != nil {
+		t.Errorf("Unexpected %t %v", ok, err)
+	}
+	// No temporary file is left behind.
+	entries, err := os.ReadDir(d.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Unexpected files %v", entries)
+	}
+}
+
+// testStore verifies the common Store behavior. advance moves the store's
+// clock forward.
+func testStore(t *testing.T, s Store, advance func(time.Duration)) {
+	t.Helper()
+	ctx := context.Background()
+	if _, ok, err := s.Get(ctx, "k"); ok || err != nil {
+		t.Fatalf("Unexpected %t %v", ok, err)
+	}
+	if err := s.Set(ctx, "k", []byte("v1"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set(ctx, "k", []byte("v2"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok, err := s.Get(ctx, "k"); !ok || err != nil || string(v) != "v2" {
+		t.Fatalf("Unexpected %q %t %v", v, ok, err)
+	}
+	advance(time.Minute)
+	if _, ok, err := s.Get(ctx, "k"); ok || err != nil {
+		t.Fatalf("Unexpected %t %v", ok, err)
+	}
+	if err := s.Set(ctx, "forever", []byte("v"), 0); err != nil {
+		t.Fatal(err)
+	}
+	advance(24 * time.Hour)
+	if _, ok, err := s.Get(ctx, "forever"); !ok || err != nil {
+		t.Fatalf("Unexpected %t %v", ok, err)
+	}
+	if err := s.Delete(ctx, "forever"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(ctx, "forever"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := s.Get(ctx, "forever"); ok || err != nil {
+		t.Fatalf("Unexpected %t %v", ok, err)
+	}
+}
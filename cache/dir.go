@@ -0,0 +1,98 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Dir is a Store keeping one file per entry in a directory, so the cache
+// survives restarts. Expired entries are deleted when read.
+type Dir struct {
+	dir string
+	now func() time.Time
+}
+
+// NewDir returns a Dir storing its files in dir, created as needed.
+func NewDir(dir string) *Dir {
+	return &Dir{dir: dir, now: time.Now}
+}
+
+// Get implements Store.
+func (d *Dir) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	p := d.path(key)
+	b, err := os.ReadFile(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if len(b) < 8 {
+		// Corrupted; treat as missing.
+		_ = os.Remove(p)
+		return nil, false, nil
+	}
+	if exp := int64(binary.BigEndian.Uint64(b)); exp != 0 && d.now().UnixNano() >= exp {
+		_ = os.Remove(p)
+		return nil, false, nil
+	}
+	return b[8:], true, nil
+}
+
+// Set implements Store.
+//
+// The file is written atomically, so concurrent readers never see a partial
+// entry.
+func (d *Dir) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := os.MkdirAll(d.dir, 0o700); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(d.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	var hdr [8]byte
+	if ttl > 0 {
+		binary.BigEndian.PutUint64(hdr[:], uint64(d.now().Add(ttl).UnixNano()))
+	}
+	_, err = f.Write(hdr[:])
+	if err == nil {
+		_, err = f.Write(value)
+	}
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), d.path(key))
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+	}
+	return err
+}
+
+// Delete implements Store.
+func (d *Dir) Delete(ctx context.Context, key string) error {
+	if err := os.Remove(d.path(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// path returns the file for key. Keys are hashed since they are URLs with
+// headers.
+func (d *Dir) path(key string) string {
+	h := sha256.Sum256([]byte(key))
+	return filepath.Join(d.dir, hex.EncodeToString(h[:]))
+}
@@ -0,0 +1,54 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/maruel/httpjson"
+	"github.com/maruel/httpjson/cache"
+)
+
+// redisClient is the subset of a Redis client, like
+// github.com/redis/go-redis, needed by redisStore.
+type redisClient interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Del(ctx context.Context, keys ...string) error
+}
+
+// errRedisNil is what the Redis client returns for a missing key.
+var errRedisNil = errors.New("redis: nil")
+
+// redisStore adapts a Redis client to cache.Store, sharing the cache between
+// processes.
+type redisStore struct {
+	c redisClient
+}
+
+func (r *redisStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	v, err := r.c.Get(ctx, key)
+	if errors.Is(err, errRedisNil) {
+		return nil, false, nil
+	}
+	return v, err == nil, err
+}
+
+func (r *redisStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return r.c.Set(ctx, key, value, ttl)
+}
+
+func (r *redisStore) Delete(ctx context.Context, key string) error {
+	return r.c.Del(ctx, key)
+}
+
+func Example_redis() {
+	var rdb redisClient // e.g. redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+	var s cache.Store = &redisStore{c: rdb}
+	c := &httpjson.Client{Cache: httpjson.NewCache(s, time.Minute)}
+	_ = c
+}
@@ -0,0 +1,304 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/maruel/httpjson/cache"
+)
+
+func TestClient_Get_cache(t *testing.T) {
+	t.Parallel()
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		_, _ = w.Write([]byte(`{"output":"` + r.URL.Path + `"}`))
+	}))
+	defer ts.Close()
+	clk := &fakeClock{now: time.Unix(1000, 0)}
+	s := cache.NewMemory(2)
+	m := NewCache(s, time.Minute)
+	m.Clock = clk
+	c := Client{Cache: m}
+	get := func(path string, hdr http.Header) string {
+		var out struct {
+			Output string `json:"output"`
+		}
+		if err := c.Get(context.Background(), ts.URL+path, hdr, &out); err != nil {
+			t.Fatal(err)
+		}
+		return out.Output
+	}
+	check := func(want int32) {
+		t.Helper()
+		if got := calls.Load(); got != want {
+			t.Errorf("Unexpected\nwant: %v\ngot:  %v", want, got)
+		}
+	}
+
+	if got := get("/a", nil); got != "/a" {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", "/a", got)
+	}
+	if got := get("/a", nil); got != "/a" {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", "/a", got)
+	}
+	check(1)
+	// Headers are part of the key.
+	get("/a", http.Header{"x-test": {"1"}})
+	check(2)
+	get("/a", http.Header{"X-Test": {"1"}})
+	check(2)
+	// Eviction of the least recently used entry.
+	get("/b", nil)
+	check(3)
+	if l := s.Len(); l != 2 {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", 2, l)
+	}
+	get("/a", nil)
+	check(4)
+	// Expiration.
+	clk.Advance(time.Minute)
+	get("/b", nil)
+	check(5)
+	// Errors are not cached.
+	get("/fail", nil)
+	get("/fail", nil)
+	check(7)
+	s.Purge()
+	if l := s.Len(); l != 0 {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", 0, l)
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	t.Parallel()
+	a := cacheKey("http://x", http.Header{"B": {"2"}, "a": {"1"}})
+	b := cacheKey("http://x", http.Header{"A": {"1"}, "B": {"2"}})
+	if a != b {
+		t.Errorf("Unexpected\nwant: %q\ngot:  %q", b, a)
+	}
+	if c := cacheKey("http://y", http.Header{"A": {"1"}, "B": {"2"}}); c == a {
+		t.Errorf("Unexpected key collision %q", c)
+	}
+}
+
+func TestClient_Get_cache_stale(t *testing.T) {
+	t.Parallel()
+	var calls atomic.Int32
+	var fail atomic.Bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if fail.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = w.Write([]byte(`{}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"n":` + strconv.Itoa(int(n)) + `}`))
+	}))
+	defer ts.Close()
+	clk := &fakeClock{now: time.Unix(1000, 0)}
+	s := cache.NewMemory(0)
+	m := NewCache(s, time.Minute)
+	m.StaleWhileRevalidate = time.Minute
+	m.StaleIfError = time.Hour
+	m.Clock = clk
+	advance := clk.Advance
+	c := Client{Cache: m}
+	get := func() int {
+		var out struct {
+			N int `json:"n"`
+		}
+		if err := c.Get(context.Background(), ts.URL, nil, &out); err != nil {
+			t.Fatal(err)
+		}
+		return out.N
+	}
+	if n := get(); n != 1 {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", 1, n)
+	}
+	// Stale while revalidate: the stale value is returned and refreshed in
+	// the background.
+	advance(time.Minute + time.Second)
+	if n := get(); n != 1 {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", 1, n)
+	}
+	for calls.Load() != 2 || s.Len() != 1 || get() != 2 {
+		time.Sleep(time.Millisecond)
+	}
+	// Stale if error.
+	fail.Store(true)
+	advance(10 * time.Minute)
+	if n := get(); n != 2 {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", 2, n)
+	}
+	// Past both windows, the 502 response is used as is.
+	advance(time.Hour)
+	var out struct {
+		N int `json:"n"`
+	}
+	if err := c.Get(context.Background(), ts.URL, nil, &out); err != nil || out.N != 0 {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v, %v", 0, out.N, err)
+	}
+}
+
+// keyStore records the keys it is given.
+type keyStore struct {
+	cache.Store
+	mu   sync.Mutex
+	keys []string
+}
+
+func (k *keyStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	k.mu.Lock()
+	k.keys = append(k.keys, key)
+	k.mu.Unlock()
+	return k.Store.Set(ctx, key, value, ttl)
+}
+
+func TestClient_Get_cache_hit(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/empty" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_, _ = w.Write([]byte(`{"output":"data"}`))
+	}))
+	defer ts.Close()
+	s := &keyStore{Store: cache.NewMemory(0)}
+	c := Client{Cache: NewCache(s, time.Minute), RequireJSON: true}
+	hdr := http.Header{"Authorization": {"Bearer secret"}}
+	var out struct {
+		Output string `json:"output"`
+	}
+	for range 2 {
+		if err := c.Get(context.Background(), ts.URL, hdr, &out); err != nil || out.Output != "data" {
+			t.Fatalf("Unexpected %q %v", out.Output, err)
+		}
+	}
+	// Credentials don't leak in the store keys.
+	s.mu.Lock()
+	for _, k := range s.keys {
+		if strings.Contains(k, "secret") || strings.Contains(k, ts.URL) {
+			t.Errorf("Unexpected key %q", k)
+		}
+	}
+	s.mu.Unlock()
+	// A hit that fails to decode returns the same *Error as a network
+	// response would.
+	var other struct {
+		Other string `json:"other"`
+	}
+	err := c.Get(context.Background(), ts.URL, hdr, &other)
+	var herr *Error
+	if !errors.As(err, &herr) || herr.StatusCode != 200 || herr.Method != "GET" || herr.URL != ts.URL || string(herr.ResponseBody) != `{"output":"data"}` {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// Empty bodies are handled like on the network path.
+	for range 2 {
+		if err := c.Get(context.Background(), ts.URL+"/empty", nil, &out); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestClient_Get_cache_revalidate_timeout(t *testing.T) {
+	t.Parallel()
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) > 1 {
+			// Hang until the client gives up.
+			<-r.Context().Done()
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_, _ = w.Write([]byte(`{"n":1}`))
+	}))
+	defer ts.Close()
+	clk := &fakeClock{now: time.Unix(1000, 0)}
+	m := NewCache(cache.NewMemory(0), time.Minute)
+	m.StaleWhileRevalidate = time.Hour
+	m.RevalidateTimeout = 10 * time.Millisecond
+	m.Clock = clk
+	c := Client{Cache: m}
+	get := func() {
+		var out struct {
+			N int `json:"n"`
+		}
+		if err := c.Get(context.Background(), ts.URL, nil, &out); err != nil || out.N != 1 {
+			t.Fatalf("Unexpected %d, %v", out.N, err)
+		}
+	}
+	get()
+	clk.Advance(2 * time.Minute)
+	// Each stale hit starts a refresh once the previous one timed out.
+	for calls.Load() < 3 {
+		get()
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestClient_Get_cache_freshness(t *testing.T) {
+	t.Parallel()
+	var fail atomic.Bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_, _ = w.Write([]byte(`{"n":1}`))
+	}))
+	defer ts.Close()
+	clk := &fakeClock{now: time.Unix(1000, 0)}
+	m := NewCache(cache.NewMemory(0), time.Minute)
+	m.StaleIfError = time.Hour
+	m.Clock = clk
+	c := Client{Cache: m}
+	get := func() Freshness {
+		var f Freshness
+		var out struct {
+			N int `json:"n"`
+		}
+		if err := c.Get(WithFreshness(context.Background(), &f), ts.URL, nil, &out); err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+	data := []struct {
+		advance time.Duration
+		fail    bool
+		want    Freshness
+	}{
+		{0, false, Freshness{}},
+		{10 * time.Second, false, Freshness{Hit: true, Age: 10 * time.Second}},
+		{time.Minute, true, Freshness{Hit: true, Age: 70 * time.Second, Stale: true}},
+		{0, false, Freshness{Revalidated: true}},
+		{0, false, Freshness{Hit: true}},
+	}
+	for i, line := range data {
+		clk.Advance(line.advance)
+		fail.Store(line.fail)
+		if got := get(); got != line.want {
+			t.Errorf("#%d: Unexpected\nwant: %+v\ngot:  %+v", i, line.want, got)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"mime"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+var utf8BOM = []byte("\xEF\xBB\xBF")
+
+// toUTF8 converts b from the charset declared in contentType to UTF-8 and
+// strips the leading byte order mark, if any.
+//
+// UTF-8, US-ASCII, ISO-8859-1, Windows-1252 and UTF-16 are transcoded with
+// the standard library to stay free of external dependencies. A body in any
+// other charset is passed through as is when it is valid UTF-8, e.g. JSON
+// with only ASCII characters, and returns an error otherwise.
+func toUTF8(contentType string, b []byte) ([]byte, error) {
+	charset := ""
+	if contentType != "" {
+		if _, params, err := mime.ParseMediaType(contentType); err == nil {
+			charset = strings.ToLower(params["charset"])
+		}
+	}
+	switch charset {
+	case "", "utf-8", "utf8", "us-ascii":
+		return bytes.TrimPrefix(b, utf8BOM), nil
+	case "iso-8859-1", "latin1":
+		out := make([]byte, 0, len(b))
+		for _, c := range b {
+			out = utf8.AppendRune(out, rune(c))
+		}
+		return out, nil
+	case "windows-1252", "cp1252":
+		out := make([]byte, 0, len(b))
+		for _, c := range b {
+			r := rune(c)
+			if c >= 0x80 && c < 0xA0 {
+				r = windows1252[c-0x80]
+			}
+			out = utf8.AppendRune(out, r)
+		}
+		return out, nil
+	case "utf-16", "utf-16le", "utf-16be":
+		return utf16ToUTF8(charset, b)
+	default:
+		if !utf8.Valid(b) {
+			return nil, fmt.Errorf("unsupported response charset %q", charset)
+		}
+		return bytes.TrimPrefix(b, utf8BOM), nil
+	}
+}
+
+// windows1252 maps the bytes 0x80 to 0x9F of Windows-1252, the only range
+// differing from ISO-8859-1. Unassigned bytes map to the C1 control of the
+// same value.
+var windows1252 = [32]rune{
+	'\u20AC', '\u0081', '\u201A', '\u0192', '\u201E', '\u2026', '\u2020', '\u2021',
+	'\u02C6', '\u2030', '\u0160', '\u2039', '\u0152', '\u008D', '\u017D', '\u008F',
+	'\u0090', '\u2018', '\u2019', '\u201C', '\u201D', '\u2022', '\u2013', '\u2014',
+	'\u02DC', '\u2122', '\u0161', '\u203A', '\u0153', '\u009D', '\u017E', '\u0178',
+}
+
+// utf16ToUTF8 decodes b as UTF-16. "utf-16" defaults to big endian unless a
+// byte order mark says otherwise.
+func utf16ToUTF8(charset string, b []byte) ([]byte, error) {
+	if len(b)%2 != 0 {
+		return nil, fmt.Errorf("invalid %s response: odd length %d", charset, len(b))
+	}
+	var order binary.ByteOrder = binary.BigEndian
+	if charset == "utf-16le" {
+		order = binary.LittleEndian
+	}
+	if charset == "utf-16" && len(b) >= 2 {
+		switch {
+		case b[0] == 0xFF && b[1] == 0xFE:
+			order = binary.LittleEndian
+			b = b[2:]
+		case b[0] == 0xFE && b[1] == 0xFF:
+			b = b[2:]
+		}
+	}
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = order.Uint16(b[2*i:])
+	}
+	r := utf16.Decode(u)
+	if len(r) != 0 && r[0] == '\uFEFF' {
+		r = r[1:]
+	}
+	return []byte(string(r)), nil
+}
@@ -0,0 +1,70 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestToUTF8(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		in          string
+		want        string
+		err         string
+	}{
+		{"none", "", `{"a":"é"}`, `{"a":"é"}`, ""},
+		{"bom", "application/json", "\xEF\xBB\xBF{}", "{}", ""},
+		{"utf-8 bom", "application/json; charset=UTF-8", "\xEF\xBB\xBF{}", "{}", ""},
+		{"latin1", "application/json; charset=ISO-8859-1", "\"\xe9\"", `"é"`, ""},
+		{"utf-16le", "application/json; charset=utf-16le", "{\x00}\x00", "{}", ""},
+		{"utf-16be", "application/json; charset=utf-16be", "\x00{\x00}", "{}", ""},
+		{"utf-16 bom le", "application/json; charset=utf-16", "\xff\xfe{\x00}\x00", "{}", ""},
+		{"utf-16 bom be", "application/json; charset=utf-16", "\xfe\xff\x00{\x00}", "{}", ""},
+		{"utf-16 odd", "application/json; charset=utf-16", "\x00{\x00", "", "invalid utf-16 response: odd length 3"},
+		{"windows-1252", "application/json; charset=windows-1252", "{\"a\":\"\x93\xe9\x94\"}", `{"a":"“é”"}`, ""},
+		{"unsupported ascii", "application/json; charset=shift_jis", `{"a":"b"}`, `{"a":"b"}`, ""},
+		{"unsupported", "application/json; charset=shift_jis", "{\"a\":\"\x82\xa0\"}", "", "unsupported response charset \"shift_jis\""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := toUTF8(tt.contentType, []byte(tt.in))
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("Unexpected\nwant: %v\ngot:  %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Unexpected\nwant: %q\ngot:  %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestClient_Get_bom(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_, _ = w.Write([]byte("\xEF\xBB\xBF{\"output\":\"data\"}"))
+	}))
+	defer ts.Close()
+	var out struct {
+		Output string `json:"output"`
+	}
+	if err := (&Client{}).Get(context.Background(), ts.URL, nil, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Output != "data" {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", "data", out.Output)
+	}
+}
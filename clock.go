@@ -0,0 +1,47 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"context"
+	"time"
+)
+
+// Clock is the source of time used by Client to wait between retries, by
+// Healthcheck to wait between polls, by Cache to expire entries and by
+// transports like Latency. Tests can provide one advancing time synthetically
+// instead of sleeping.
+type Clock interface {
+	Now() time.Time
+	// Sleep waits for d or until ctx is done, in which case it returns
+	// ctx.Err().
+	Sleep(ctx context.Context, d time.Duration) error
+}
+
+// realClock is the Clock used by default.
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) Sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// clockOr returns c, or the real clock if c is nil.
+func clockOr(c Clock) Clock {
+	if c == nil {
+		return realClock{}
+	}
+	return c
+}
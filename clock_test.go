@@ -0,0 +1,77 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/maruel/httpjson/retry"
+)
+
+// fakeClock is a Clock whose time only moves when advanced or slept on.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+	f.sleeps = append(f.sleeps, d)
+	return ctx.Err()
+}
+
+func (f *fakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
+
+func TestClient_Clock(t *testing.T) {
+	t.Parallel()
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if calls.Add(1) < 4 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+	clk := &fakeClock{now: time.Unix(1000, 0)}
+	c := Client{
+		Retry: retry.MaxRetries(3, retry.ServerErrors(retry.Exponential(time.Hour, 4*time.Hour))),
+		Clock: clk,
+	}
+	// Hours of backoff complete instantly.
+	if err := c.Get(context.Background(), ts.URL, nil, &struct{}{}); err != nil {
+		t.Fatal(err)
+	}
+	want := []time.Duration{time.Hour, 2 * time.Hour, 4 * time.Hour}
+	clk.mu.Lock()
+	defer clk.mu.Unlock()
+	if len(clk.sleeps) != len(want) {
+		t.Fatalf("Unexpected\nwant: %v\ngot:  %v", want, clk.sleeps)
+	}
+	for i := range want {
+		if clk.sleeps[i] != want[i] {
+			t.Errorf("Unexpected\nwant: %v\ngot:  %v", want, clk.sleeps)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"net/http"
+	"sync"
+)
+
+// flight is a request in progress shared by concurrent callers.
+type flight struct {
+	wg   sync.WaitGroup
+	resp *http.Response
+	body []byte
+	err  error
+}
+
+// coalesce calls fn once for all the concurrent callers using the same key
+// and returns its result to each of them.
+//
+// The response is shared thus must be treated as read only.
+func (c *Client) coalesce(key string, fn func() (*http.Response, []byte, error)) (*http.Response, []byte, error) {
+	s := c.shared()
+	s.mu.Lock()
+	if f, ok := s.flights[key]; ok {
+		s.mu.Unlock()
+		s.stats.coalesce()
+		f.wg.Wait()
+		return f.resp, f.body, f.err
+	}
+	f := &flight{}
+	f.wg.Add(1)
+	if s.flights == nil {
+		s.flights = map[string]*flight{}
+	}
+	s.flights[key] = f
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.flights, key)
+		s.mu.Unlock()
+		f.wg.Done()
+	}()
+	f.resp, f.body, f.err = fn()
+	return f.resp, f.body, f.err
+}
@@ -0,0 +1,63 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestClient_Get_coalesce(t *testing.T) {
+	t.Parallel()
+	var calls atomic.Int32
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_, _ = w.Write([]byte(`{"output":"data"}`))
+	}))
+	defer ts.Close()
+	c := Client{Coalesce: true}
+	const n = 10
+	var wg sync.WaitGroup
+	for range n {
+		wg.Go(func() {
+			var out struct {
+				Output string `json:"output"`
+			}
+			if err := c.Get(context.Background(), ts.URL, nil, &out); err != nil {
+				t.Error(err)
+			}
+			if out.Output != "data" {
+				t.Errorf("Unexpected\nwant: %v\ngot:  %v", "data", out.Output)
+			}
+		})
+	}
+	// Wait for all the calls to share the first one.
+	for c.Stats().Coalesced != n-1 {
+		runtime.Gosched()
+	}
+	close(release)
+	wg.Wait()
+	if got := calls.Load(); got != 1 {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", 1, got)
+	}
+	// Once done, a new call sends a new request.
+	var out struct {
+		Output string `json:"output"`
+	}
+	if err := c.Get(context.Background(), ts.URL, nil, &out); err != nil {
+		t.Fatal(err)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", 2, got)
+	}
+}
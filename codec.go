@@ -0,0 +1,53 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+)
+
+// marshal encodes in with c.Marshal or encoding/json.
+func (c *Client) marshal(in any) ([]byte, error) {
+	if c.Marshal != nil {
+		return c.Marshal(in)
+	}
+	buf := getBuffer()
+	defer putBuffer(buf)
+	e := json.NewEncoder(buf)
+	// OMG this took me a while to figure this out. This affects LLM token encoding.
+	e.SetEscapeHTML(false)
+	if err := e.Encode(in); err != nil {
+		return nil, err
+	}
+	return bytes.Clone(buf.Bytes()), nil
+}
+
+// decodeWith is decodeJSON using a custom unmarshal function.
+//
+// Alternative JSON libraries do not all support rejecting unknown fields, so
+// they are found with FindExtraKeys instead.
+func decodeWith(unmarshal func([]byte, any) error, b []byte, out any, lenient bool) error {
+	orig := b
+	if t := reflect.TypeOf(out); t != nil && hasFormatTags(t) {
+		var err error
+		if b, err = applyFormatTags(b, t); err != nil {
+			return err
+		}
+	}
+	if err := unmarshal(b, out); err != nil || lenient {
+		return err
+	}
+	m := map[string]any{}
+	if unmarshal(b, &m) == nil {
+		if errs := FindExtraKeys(reflect.TypeOf(out), m); len(errs) != 0 {
+			setOffsets(orig, b, errs)
+			return errors.Join(errs...)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestClient_Marshal_Unmarshal(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		if string(b) == `{"in":"<a>"}` {
+			_, _ = w.Write([]byte(`{"out":"ok"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"out":"ok","extra":1}`))
+	}))
+	defer ts.Close()
+	var marshals, unmarshals atomic.Int32
+	c := Client{
+		Marshal: func(v any) ([]byte, error) {
+			marshals.Add(1)
+			return json.Marshal(v)
+		},
+		Unmarshal: func(data []byte, v any) error {
+			unmarshals.Add(1)
+			return json.Unmarshal(data, v)
+		},
+	}
+	type Out struct {
+		Out string `json:"out"`
+	}
+	in := map[string]string{"in": "<a>"}
+	var out Out
+	// json.Marshal escapes HTML, so the server replies with an extra field.
+	err := c.Post(context.Background(), ts.URL, nil, in, &out)
+	var uerr *UnknownFieldError
+	if !errors.As(err, &uerr) || uerr.Path != "$.extra" || uerr.Offset != 12 {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if out.Out != "ok" {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", "ok", out.Out)
+	}
+	if err := c.Post(OverrideLenient(context.Background(), true), ts.URL, nil, in, &out); err != nil {
+		t.Fatal(err)
+	}
+	if m, u := marshals.Load(), unmarshals.Load(); m != 2 || u != 3 {
+		t.Errorf("Unexpected\nwant: 2, 3\ngot:  %d, %d", m, u)
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// AsCurl returns a curl command line equivalent to req, for example to attach
+// to a bug report. Secrets in the URL, headers and JSON body are redacted.
+//
+// The body is included when it can be replayed via req.GetBody, which is
+// the case for requests created by Client.Build.
+func AsCurl(req *http.Request) string {
+	args := []string{"curl"}
+	if req.Method != "" && req.Method != http.MethodGet {
+		args = append(args, "-X", req.Method)
+	}
+	for _, k := range slices.Sorted(maps.Keys(req.Header)) {
+		for _, v := range req.Header[k] {
+			if isSecretName(k) {
+				v = redacted
+			}
+			args = append(args, "-H", shellQuote(k+": "+v))
+		}
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			// The body can't be read without consuming it.
+			args = append(args, "--data-binary", "@-")
+		} else if r, err := req.GetBody(); err == nil {
+			b, _ := io.ReadAll(r)
+			_ = r.Close()
+			args = append(args, "--data-binary", shellQuote(string(redactJSON(b))))
+		}
+	}
+	return strings.Join(append(args, shellQuote(redactURL(req.URL))), " ")
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,51 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAsCurl(t *testing.T) {
+	t.Parallel()
+	c := Client{}
+	hdr := http.Header{"Authorization": {"Bearer secret"}, "X-Name": {"it's"}}
+	req, err := c.Build(context.Background(), "POST", "https://example.com/api?token=secret&a=1", hdr, map[string]string{"in": "o'k"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `curl -X POST -H 'Accept: application/json' -H 'Authorization: REDACTED' -H 'Content-Type: application/json; charset=utf-8' -H 'X-Name: it'\''s' --data-binary '{"in":"o'\''k"}` + "\n" + `' 'https://example.com/api?a=1&token=REDACTED'`
+	if got := AsCurl(req); got != want {
+		t.Errorf("Unexpected\nwant: %s\ngot:  %s", want, got)
+	}
+
+	req, err = c.Build(context.Background(), "POST", "https://example.com/login", nil, map[string]string{"user": "joe", "password": "hunter2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := AsCurl(req); strings.Contains(got, "hunter2") || !strings.Contains(got, `"password":"REDACTED"`) {
+		t.Errorf("Unexpected %s", got)
+	}
+
+	req, err = http.NewRequest("GET", "https://example.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := AsCurl(req), `curl 'https://example.com/'`; got != want {
+		t.Errorf("Unexpected\nwant: %s\ngot:  %s", want, got)
+	}
+
+	req, err = http.NewRequest("PUT", "https://example.com/", strings.NewReader("x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.GetBody = nil
+	if got, want := AsCurl(req), `curl -X PUT --data-binary @- 'https://example.com/'`; got != want {
+		t.Errorf("Unexpected\nwant: %s\ngot:  %s", want, got)
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// DecodeError is returned by DecodeResponse and DecodeResponseBody when the
+// response body can't be decoded into any of the outputs.
+type DecodeError struct {
+	// Errs are the decoding errors, one per output.
+	Errs []error
+	// Closest is the index of the output that came the closest to matching:
+	// the one with the fewest unknown fields, then with the deepest type
+	// mismatch.
+	Closest int
+}
+
+// Error returns a one line summary based on the closest output.
+func (d *DecodeError) Error() string {
+	msg, extra, _ := strings.Cut(d.Errs[d.Closest].Error(), "\n")
+	if extra != "" {
+		msg += fmt.Sprintf(" (and %d more)", strings.Count(extra, "\n")+1)
+	}
+	if len(d.Errs) == 1 {
+		return "failed to decode server response: " + msg
+	}
+	return fmt.Sprintf("failed to decode server response into any of %d outputs; closest is #%d: %s", len(d.Errs), d.Closest, msg)
+}
+
+// Unwrap returns all the decoding errors.
+func (d *DecodeError) Unwrap() []error {
+	return d.Errs
+}
+
+func newDecodeError(errs []error) *DecodeError {
+	d := &DecodeError{Errs: errs}
+	bestUnknown, bestDepth := -1, 0
+	for i, err := range errs {
+		unknown, depth := mismatch(err)
+		if bestUnknown == -1 || unknown < bestUnknown || (unknown == bestUnknown && depth > bestDepth) {
+			d.Closest, bestUnknown, bestDepth = i, unknown, depth
+		}
+	}
+	return d
+}
+
+// mismatch returns the number of unknown fields and the depth of the
+// deepest type mismatch in err.
+func mismatch(err error) (unknown, depth int) {
+	switch e := err.(type) {
+	case *UnknownFieldError:
+		unknown++
+	case *json.UnmarshalTypeError:
+		depth = strings.Count(e.Field, ".") + 1
+	}
+	switch e := err.(type) {
+	case interface{ Unwrap() []error }:
+		for _, w := range e.Unwrap() {
+			u, d := mismatch(w)
+			unknown += u
+			depth = max(depth, d)
+		}
+	case interface{ Unwrap() error }:
+		if w := e.Unwrap(); w != nil {
+			u, d := mismatch(w)
+			unknown += u
+			depth = max(depth, d)
+		}
+	}
+	return unknown, depth
+}
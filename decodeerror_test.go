@@ -0,0 +1,58 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDecodeError(t *testing.T) {
+	t.Parallel()
+	resp := &http.Response{StatusCode: 200, Status: "200 OK", Body: io.NopCloser(strings.NewReader(`{"a":1,"b":{"c":"x"},"d":2}`))}
+	var far struct {
+		Z int `json:"z"`
+	}
+	var wrongType struct {
+		A int `json:"a"`
+		B struct {
+			C int `json:"c"`
+		} `json:"b"`
+		D int `json:"d"`
+	}
+	var oneUnknown struct {
+		A int `json:"a"`
+		B struct {
+			C string `json:"c"`
+		} `json:"b"`
+	}
+	i, err := DecodeResponse(resp, &far, &oneUnknown, &wrongType)
+	if i != -1 {
+		t.Fatalf("Unexpected\nwant: %v\ngot:  %v", -1, i)
+	}
+	var derr *DecodeError
+	if !errors.As(err, &derr) {
+		t.Fatalf("expected DecodeError, got %v", err)
+	}
+	if len(derr.Errs) != 3 || derr.Closest != 2 {
+		t.Errorf("Unexpected %d %d", len(derr.Errs), derr.Closest)
+	}
+	// Summary mentions the extra errors of the closest.
+	d := &DecodeError{Errs: []error{errors.Join(errors.New("first"), errors.New("second"), errors.New("third"))}}
+	if want := "failed to decode server response: first (and 2 more)"; d.Error() != want {
+		t.Errorf("Unexpected\nwant: %q\ngot:  %q", want, d.Error())
+	}
+	// The fewest unknown fields wins.
+	d = newDecodeError([]error{
+		&UnknownFieldError{},
+		errors.Join(&UnknownFieldError{}, &UnknownFieldError{}),
+	})
+	if d.Closest != 0 {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", 0, d.Closest)
+	}
+}
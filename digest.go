@@ -0,0 +1,141 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DigestError is returned when a response body doesn't match the digest
+// announced in its headers.
+type DigestError struct {
+	// Header is the name of the header holding the expected digest.
+	Header    string
+	Algorithm string
+	Want      string
+	Got       string
+}
+
+func (d *DigestError) Error() string {
+	return fmt.Sprintf("response body %s mismatch in %s: want %s, got %s", d.Algorithm, d.Header, d.Want, d.Got)
+}
+
+// expectedDigest is a digest announced in a response header.
+type expectedDigest struct {
+	header    string
+	algorithm string
+	sum       []byte
+}
+
+// parseDigests returns the sha-256 and md5 digests found in the
+// Content-Digest (RFC 9530), Digest (RFC 3230) and Content-MD5 headers.
+func parseDigests(h http.Header) []expectedDigest {
+	var out []expectedDigest
+	add := func(header, algorithm, value string) {
+		algorithm = strings.ToLower(strings.TrimSpace(algorithm))
+		if algorithm != "sha-256" && algorithm != "md5" {
+			return
+		}
+		if b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value)); err == nil {
+			out = append(out, expectedDigest{header: header, algorithm: algorithm, sum: b})
+		}
+	}
+	for _, v := range h.Values("Content-Digest") {
+		for item := range strings.SplitSeq(v, ",") {
+			if algo, val, ok := strings.Cut(item, "="); ok {
+				add("Content-Digest", algo, strings.Trim(strings.TrimSpace(val), ":"))
+			}
+		}
+	}
+	for _, v := range h.Values("Digest") {
+		for item := range strings.SplitSeq(v, ",") {
+			if algo, val, ok := strings.Cut(item, "="); ok {
+				add("Digest", algo, val)
+			}
+		}
+	}
+	if v := h.Get("Content-MD5"); v != "" {
+		add("Content-MD5", "md5", v)
+	}
+	return out
+}
+
+// digestReader hashes the response body as it is read. At EOF it verifies
+// the expected digests and reports the SHA-256.
+type digestReader struct {
+	io.ReadCloser
+	resp     *http.Response
+	sha      hash.Hash
+	md5      hash.Hash
+	expected []expectedDigest
+	report   func(*http.Response, [sha256.Size]byte)
+	done     bool
+}
+
+func newDigestReader(resp *http.Response, verify bool, report func(*http.Response, [sha256.Size]byte)) *digestReader {
+	d := &digestReader{ReadCloser: resp.Body, resp: resp, sha: sha256.New(), report: report}
+	// The headers describe the encoded body, which is not what is read when
+	// the transport decompressed it.
+	if verify && !resp.Uncompressed {
+		d.expected = parseDigests(resp.Header)
+		for _, e := range d.expected {
+			if e.algorithm == "md5" {
+				d.md5 = md5.New()
+				break
+			}
+		}
+	}
+	return d
+}
+
+func (d *digestReader) Read(p []byte) (int, error) {
+	n, err := d.ReadCloser.Read(p)
+	if n > 0 {
+		d.sha.Write(p[:n])
+		if d.md5 != nil {
+			d.md5.Write(p[:n])
+		}
+	}
+	if err == io.EOF && !d.done {
+		d.done = true
+		if err2 := d.finish(); err2 != nil {
+			return n, err2
+		}
+	}
+	return n, err
+}
+
+func (d *digestReader) finish() error {
+	var sum [sha256.Size]byte
+	d.sha.Sum(sum[:0])
+	for _, e := range d.expected {
+		var got []byte
+		if e.algorithm == "md5" {
+			got = d.md5.Sum(nil)
+		} else {
+			got = sum[:]
+		}
+		if !bytes.Equal(got, e.sum) {
+			return &DigestError{
+				Header:    e.header,
+				Algorithm: e.algorithm,
+				Want:      base64.StdEncoding.EncodeToString(e.sum),
+				Got:       base64.StdEncoding.EncodeToString(got),
+			}
+		}
+	}
+	if d.report != nil {
+		d.report(d.resp, sum)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient_Get_digest(t *testing.T) {
+	t.Parallel()
+	const body = `{"output":"data"}`
+	sha := sha256.Sum256([]byte(body))
+	md := md5.Sum([]byte(body))
+	shaB64 := base64.StdEncoding.EncodeToString(sha[:])
+	mdB64 := base64.StdEncoding.EncodeToString(md[:])
+	bad := base64.StdEncoding.EncodeToString(make([]byte, sha256.Size))
+	data := []struct {
+		name   string
+		header http.Header
+		want   string
+	}{
+		{"none", nil, ""},
+		{"content-digest", http.Header{"Content-Digest": {"sha-256=:" + shaB64 + ":"}}, ""},
+		{"digest", http.Header{"Digest": {"MD5=" + mdB64 + ", SHA-256=" + shaB64}}, ""},
+		{"content-md5", http.Header{"Content-Md5": {mdB64}}, ""},
+		{"bad", http.Header{"Content-Digest": {"sha-256=:" + bad + ":"}}, "Content-Digest"},
+		{"bad-md5", http.Header{"Content-Md5": {shaB64}}, "Content-MD5"},
+	}
+	for _, line := range data {
+		t.Run(line.name, func(t *testing.T) {
+			t.Parallel()
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for k, v := range line.header {
+					w.Header()[k] = v
+				}
+				w.Header().Set("Content-Type", "application/json; charset=utf-8")
+				_, _ = w.Write([]byte(body))
+			}))
+			defer ts.Close()
+			var got [sha256.Size]byte
+			c := Client{VerifyDigest: true, BodySHA256: func(resp *http.Response, sum [sha256.Size]byte) { got = sum }}
+			var out struct {
+				Output string `json:"output"`
+			}
+			err := c.Get(context.Background(), ts.URL, nil, &out)
+			if line.want == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != sha {
+					t.Errorf("Unexpected\nwant: %x\ngot:  %x", sha, got)
+				}
+				return
+			}
+			var derr *DigestError
+			if !errors.As(err, &derr) {
+				t.Fatalf("expected DigestError, got %v", err)
+			}
+			if derr.Header != line.want {
+				t.Errorf("Unexpected\nwant: %v\ngot:  %v", line.want, derr.Header)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// dumpSeq makes dump file names unique when exchanges complete within the
+// same clock tick.
+var dumpSeq atomic.Uint64
+
+// dumpExchange writes the request and response to a new file in dir.
+//
+// body is the already read response body. Secret-named fields in JSON request
+// and response bodies are redacted; other bodies are written as is.
+func dumpExchange(dir string, resp *http.Response, body []byte) error {
+	buf := &bytes.Buffer{}
+	if req := resp.Request; req != nil {
+		fmt.Fprintf(buf, "%s %s\n", req.Method, redactURL(req.URL))
+		writeHeaders(buf, req.Header)
+		buf.WriteString("\n")
+		if req.GetBody != nil {
+			if r, err := req.GetBody(); err == nil {
+				b, _ := io.ReadAll(r)
+				_ = r.Close()
+				buf.Write(redactJSON(b))
+			}
+		}
+		buf.WriteString("\n\n")
+	}
+	fmt.Fprintf(buf, "%s %s\n", resp.Proto, resp.Status)
+	writeHeaders(buf, resp.Header)
+	buf.WriteString("\n")
+	buf.Write(redactJSON(body))
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%d-%d.txt", time.Now().UTC().Format("20060102T150405.000000000"), dumpSeq.Add(1), resp.StatusCode)
+	return os.WriteFile(filepath.Join(dir, name), buf.Bytes(), 0o600)
+}
+
+// writeHeaders writes the headers sorted by name, with secrets redacted.
+func writeHeaders(w io.Writer, h http.Header) {
+	for _, k := range slices.Sorted(maps.Keys(h)) {
+		for _, v := range h[k] {
+			if isSecretName(k) {
+				v = redacted
+			}
+			fmt.Fprintf(w, "%s: %s\n", k, v)
+		}
+	}
+}
+
+const redacted = "REDACTED"
+
+// isSecretName reports whether a header or query parameter name likely holds a
+// secret.
+func isSecretName(name string) bool {
+	n := strings.ToLower(name)
+	switch n {
+	case "authorization", "proxy-authorization", "cookie", "set-cookie", "sig", "signature", "code":
+		return true
+	}
+	for _, s := range []string{"key", "token", "secret", "password", "auth"} {
+		if strings.Contains(n, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactURL returns u as a string with the user password and secret query
+// parameters redacted.
+func redactURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	c := *u
+	if u.RawQuery != "" {
+		q := u.Query()
+		for k, v := range q {
+			if isSecretName(k) {
+				for i := range v {
+					v[i] = redacted
+				}
+			}
+		}
+		c.RawQuery = q.Encode()
+	}
+	return c.Redacted()
+}
+
+// redactJSON returns b with the values of secret-named object members
+// replaced. b is returned unchanged when it is not JSON or holds no secret.
+func redactJSON(b []byte) []byte {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var v any
+	if d.Decode(&v) != nil || d.InputOffset() != int64(len(bytes.TrimRight(b, " \t\r\n"))) {
+		return b
+	}
+	if !redactValue(v) {
+		return b
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return b
+	}
+	return out
+}
+
+// redactValue replaces in place the values of secret-named members found in v
+// and reports whether any was found.
+func redactValue(v any) bool {
+	found := false
+	switch t := v.(type) {
+	case map[string]any:
+		for k, e := range t {
+			if isSecretName(k) {
+				t[k] = redacted
+				found = true
+			} else if redactValue(e) {
+				found = true
+			}
+		}
+	case []any:
+		for _, e := range t {
+			if redactValue(e) {
+				found = true
+			}
+		}
+	}
+	return found
+}
@@ -0,0 +1,132 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// DuplicateKeyError is a key present more than once in the same JSON object.
+//
+// For objects decoded into a struct, keys that only differ by case are
+// duplicates too, since encoding/json matches them to the same field.
+type DuplicateKeyError struct {
+	// Field is the path to the duplicate key, e.g. "items[2].id".
+	Field string
+}
+
+// Error implements the error interface.
+func (e *DuplicateKeyError) Error() string {
+	return fmt.Sprintf("duplicate key %s", e.Field)
+}
+
+// checkDuplicateKeys returns joined *DuplicateKeyError for all the duplicate
+// keys in b, which is to be decoded into a value of type t. t may be nil.
+func checkDuplicateKeys(b []byte, t reflect.Type) error {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var errs []error
+	if walkDuplicateKeys(d, "", t, &errs) != nil {
+		// Let the real decoder report the error.
+		return nil
+	}
+	return errors.Join(errs...)
+}
+
+// walkDuplicateKeys reads one JSON value from d. t is the type the value is
+// decoded into, or nil when unknown.
+func walkDuplicateKeys(d *json.Decoder, prefix string, t reflect.Type, errs *[]error) error {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	tok, err := d.Token()
+	if err != nil {
+		return err
+	}
+	switch tok {
+	case json.Delim('{'):
+		isStruct := t != nil && t.Kind() == reflect.Struct
+		seen := map[string]struct{}{}
+		for d.More() {
+			if tok, err = d.Token(); err != nil {
+				return err
+			}
+			key, _ := tok.(string)
+			p := key
+			if prefix != "" {
+				p = prefix + "." + key
+			}
+			k := key
+			if isStruct {
+				k = foldKey(key)
+			}
+			if _, ok := seen[k]; ok {
+				*errs = append(*errs, &DuplicateKeyError{Field: p})
+			}
+			seen[k] = struct{}{}
+			if err = walkDuplicateKeys(d, p, memberType(t, key), errs); err != nil {
+				return err
+			}
+		}
+		_, err = d.Token()
+	case json.Delim('['):
+		for i := 0; d.More(); i++ {
+			if err = walkDuplicateKeys(d, fmt.Sprintf("%s[%d]", prefix, i), elemType(t), errs); err != nil {
+				return err
+			}
+		}
+		_, err = d.Token()
+	}
+	return err
+}
+
+// foldKey returns key in a form equal for all the keys encoding/json matches
+// to the same struct field.
+func foldKey(key string) string {
+	return strings.ToLower(strings.ToUpper(key))
+}
+
+// memberType returns the type the member key of an object decoded into t is
+// decoded into, or nil when unknown.
+func memberType(t reflect.Type, key string) reflect.Type {
+	if t == nil {
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Map:
+		return t.Elem()
+	case reflect.Struct:
+		fields := collectJSONFields(t)
+		name, ok := fields[key]
+		if !ok {
+			for jsonName, goName := range fields {
+				if strings.EqualFold(jsonName, key) {
+					name, ok = goName, true
+					break
+				}
+			}
+		}
+		if ok {
+			if f, found := t.FieldByName(name); found {
+				return f.Type
+			}
+		}
+	}
+	return nil
+}
+
+// elemType returns the type the items of an array decoded into t are decoded
+// into, or nil when unknown.
+func elemType(t reflect.Type) reflect.Type {
+	if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+		return t.Elem()
+	}
+	return nil
+}
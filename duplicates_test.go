@@ -0,0 +1,77 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestCheckDuplicateKeys(t *testing.T) {
+	type item struct {
+		ID int `json:"id"`
+	}
+	type doc struct {
+		ID    int               `json:"id"`
+		Items []item            `json:"items"`
+		Tags  map[string]string `json:"tags"`
+		Any   any               `json:"any"`
+	}
+	tests := []struct {
+		name string
+		data string
+		typ  any
+		want string
+	}{
+		{"none", `{"a":1,"b":{"a":2},"c":[{"a":1},{"a":2}]}`, nil, ""},
+		{"root", `{"role":"user","role":"admin"}`, nil, "duplicate key role"},
+		{"nested", `{"a":{"b":1,"b":2}}`, nil, "duplicate key a.b"},
+		{"array", `{"items":[{"id":1},{"id":2,"id":3}]}`, nil, "duplicate key items[1].id"},
+		{"multiple", `[{"a":1,"a":2,"a":3}]`, nil, "duplicate key [0].a\nduplicate key [0].a"},
+		{"syntax", `{"a":1,"a":`, nil, ""},
+		{"fold struct", `{"id":1,"ID":2}`, doc{}, "duplicate key ID"},
+		{"fold struct ptr", `{"Id":1,"iD":2}`, &doc{}, "duplicate key iD"},
+		{"fold nested", `{"items":[{"id":1},{"id":2,"Id":3}]}`, doc{}, "duplicate key items[1].Id"},
+		{"fold field", `{"Items":[{"id":1,"ID":2}]}`, doc{}, "duplicate key Items[0].ID"},
+		{"fold map", `{"tags":{"a":"x","A":"y"}}`, doc{}, ""},
+		{"fold any", `{"any":{"a":1,"A":2}}`, doc{}, ""},
+		{"fold untyped", `{"id":1,"ID":2}`, nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+			if err := checkDuplicateKeys([]byte(tt.data), reflect.TypeOf(tt.typ)); err != nil {
+				got = err.Error()
+			}
+			if got != tt.want {
+				t.Errorf("Unexpected\nwant: %q\ngot:  %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestClient_decode_duplicate_keys(t *testing.T) {
+	var out struct {
+		Role string `json:"role"`
+	}
+	c := Client{DisallowDuplicateKeys: true}
+	if err := c.decode(context.Background(), []byte(`{"role":"user","role":"admin"}`), &out); err == nil || err.Error() != "duplicate key role" {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if out.Role != "" {
+		t.Errorf("Unexpected %q", out.Role)
+	}
+}
+
+func TestClient_decode_duplicate_keys_fold(t *testing.T) {
+	var out struct {
+		ID int `json:"id"`
+	}
+	c := Client{DisallowDuplicateKeys: true}
+	if err := c.decode(context.Background(), []byte(`{"id":1,"ID":2}`), &out); err == nil || err.Error() != "duplicate key ID" {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
@@ -149,7 +149,7 @@ func ExampleClient_GetRequest() {
 		fmt.Printf("Fallback: %s\n", fallback.Error)
 		var herr *httpjson.Error
 		if errors.As(err, &herr) {
-			fmt.Printf("httpjson.Error: %v", herr)
+			fmt.Printf("httpjson.Error: http %d\n%s", herr.StatusCode, herr.ResponseBody)
 		}
 	case -1:
 		// No decoding happened. Handle various kinds of errors.
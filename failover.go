@@ -0,0 +1,139 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maruel/httpjson/retry"
+)
+
+// Failover is an http.RoundTripper sending the requests to the first healthy
+// endpoint of an ordered list, for APIs offered in multiple regions.
+//
+// An endpoint failing with a transport error or a 5xx status is skipped for
+// Cooldown, then tried again so the preferred endpoints are used again once
+// they recover. The request is sent to the next endpoint right away if it is
+// safe to send twice, see retry.IsIdempotent, and its body can be replayed.
+//
+// A Failover must not be copied after first use.
+type Failover struct {
+	// Transport sends the requests. Defaults to http.DefaultTransport.
+	Transport http.RoundTripper
+	// Endpoints are the base URLs in order of preference, e.g.
+	// "https://us.example.com". Their scheme and host replace the request's
+	// and their path prefixes it.
+	Endpoints []string
+	// Cooldown is how long a failed endpoint is skipped. Defaults to 30
+	// seconds.
+	Cooldown time.Duration
+	// Clock is used to expire the cooldowns. Defaults to the real time.
+	Clock Clock
+
+	mu        sync.Mutex
+	downUntil map[string]time.Time
+}
+
+// RoundTrip implements http.RoundTripper.
+func (f *Failover) RoundTrip(req *http.Request) (*http.Response, error) {
+	if len(f.Endpoints) == 0 {
+		closeBody(req)
+		return nil, errors.New("failover: no endpoint")
+	}
+	t := transportOr(f.Transport)
+	again := retry.IsIdempotent(req) && (req.Body == nil || req.Body == http.NoBody || req.GetBody != nil)
+	var resp *http.Response
+	var err error
+	for i, e := range f.order() {
+		if i != 0 {
+			if !again {
+				break
+			}
+			if resp != nil {
+				_, _ = io.Copy(io.Discard, resp.Body)
+				_ = resp.Body.Close()
+			}
+		}
+		base, perr := url.Parse(e)
+		if perr != nil {
+			closeBody(req)
+			return nil, perr
+		}
+		r := req.Clone(req.Context())
+		rebaseURL(r, base)
+		if i != 0 && req.GetBody != nil {
+			if r.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+		resp, err = t.RoundTrip(r)
+		if err != nil && req.Context().Err() != nil {
+			return nil, err
+		}
+		if err == nil && resp.StatusCode < 500 {
+			f.setDown(e, time.Time{})
+			return resp, nil
+		}
+		cooldown := f.Cooldown
+		if cooldown <= 0 {
+			cooldown = defaultCooldown
+		}
+		f.setDown(e, clockOr(f.Clock).Now().Add(cooldown))
+	}
+	return resp, err
+}
+
+// order returns the endpoints to try: the healthy ones in order of
+// preference, then the ones in cooldown.
+func (f *Failover) order() []string {
+	now := clockOr(f.Clock).Now()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	up := make([]string, 0, len(f.Endpoints))
+	var down []string
+	for _, e := range f.Endpoints {
+		if now.Before(f.downUntil[e]) {
+			down = append(down, e)
+		} else {
+			up = append(up, e)
+		}
+	}
+	return append(up, down...)
+}
+
+func (f *Failover) setDown(e string, until time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if until.IsZero() {
+		delete(f.downUntil, e)
+		return
+	}
+	if f.downUntil == nil {
+		f.downUntil = map[string]time.Time{}
+	}
+	f.downUntil[e] = until
+}
+
+// defaultCooldown is used when Failover.Cooldown or Balancer.Cooldown is not
+// set.
+const defaultCooldown = 30 * time.Second
+
+// rebaseURL makes r target base instead of its original host. base's path
+// prefixes r's path. Both Path and RawPath are prefixed so escaped segments
+// like %2F are preserved.
+func rebaseURL(r *http.Request, base *url.URL) {
+	r.Host = ""
+	r.URL.Scheme = base.Scheme
+	r.URL.Host = base.Host
+	raw := strings.TrimSuffix(base.EscapedPath(), "/") + r.URL.EscapedPath()
+	r.URL.Path = strings.TrimSuffix(base.Path, "/") + r.URL.Path
+	r.URL.RawPath = raw
+}
@@ -0,0 +1,101 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFailover(t *testing.T) {
+	t.Parallel()
+	var primaryDown atomic.Bool
+	var primaryCalls, secondaryCalls atomic.Int32
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		primaryCalls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		if primaryDown.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`{"from":"down"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"from":"primary` + r.URL.Path + `"}`))
+	}))
+	defer primary.Close()
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secondaryCalls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"from":"secondary` + r.URL.Path + `"}`))
+	}))
+	defer secondary.Close()
+	clk := &fakeClock{now: time.Unix(1000, 0)}
+	f := &Failover{Endpoints: []string{primary.URL + "/v1", secondary.URL + "/v2"}, Clock: clk}
+	c := Client{Client: &http.Client{Transport: f}}
+	get := func(want string) {
+		t.Helper()
+		var out struct {
+			From string `json:"from"`
+		}
+		if err := c.Get(context.Background(), "http://api.example.com/items", nil, &out); err != nil {
+			t.Fatal(err)
+		}
+		if out.From != want {
+			t.Errorf("Unexpected\nwant: %v\ngot:  %v", want, out.From)
+		}
+	}
+	get("primary/v1/items")
+	// Fails over.
+	primaryDown.Store(true)
+	get("secondary/v2/items")
+	if p, s := primaryCalls.Load(), secondaryCalls.Load(); p != 2 || s != 1 {
+		t.Errorf("Unexpected calls %d, %d", p, s)
+	}
+	// The primary is skipped while in cooldown, even once it recovered.
+	primaryDown.Store(false)
+	get("secondary/v2/items")
+	if p := primaryCalls.Load(); p != 2 {
+		t.Errorf("Unexpected calls %d", p)
+	}
+	// Then tried again.
+	clk.Advance(time.Minute)
+	get("primary/v1/items")
+	// A POST may have been processed so it is not sent twice.
+	primaryDown.Store(true)
+	clk.Advance(time.Minute)
+	var herr *Error
+	if err := c.Post(context.Background(), "http://api.example.com/items", nil, map[string]string{}, &struct{}{}); !errors.As(err, &herr) || herr.StatusCode != 503 {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if s := secondaryCalls.Load(); s != 2 {
+		t.Errorf("Unexpected calls %d", s)
+	}
+}
+
+func TestFailover_escapedPath(t *testing.T) {
+	t.Parallel()
+	paths := make(chan string, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths <- r.URL.EscapedPath()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+	c := Client{Client: &http.Client{Transport: &Failover{Endpoints: []string{ts.URL + "/v1"}}}}
+	u, err := ExpandURL("http://api.example.com/users/{id}", map[string]string{"id": "a/b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(context.Background(), u, nil, &struct{}{}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := <-paths, "/v1/users/a%2Fb"; got != want {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", want, got)
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+)
+
+// ErrInjectedFault is the error returned by Fault when no other fault is
+// configured.
+var ErrInjectedFault = errors.New("injected fault")
+
+// Fault is an http.RoundTripper injecting failures in a fraction of the
+// requests, so error paths can be tested against realistic failures without
+// modifying the servers.
+//
+// The first fault configured in field order is injected: Err, StatusCode,
+// Malformed then Truncate. With none configured, ErrInjectedFault is
+// returned.
+type Fault struct {
+	// Transport sends the requests. Defaults to http.DefaultTransport.
+	Transport http.RoundTripper
+	// Match selects the requests faults may be injected in. nil matches all
+	// of them.
+	Match func(*http.Request) bool
+	// Probability is the chance, between 0 and 1, that a matched request
+	// gets a fault.
+	Probability float64
+
+	// Err fails the request with this error without sending it.
+	Err error
+	// StatusCode replies with this status code and Body without sending the
+	// request.
+	StatusCode int
+	// Body is the JSON body of the response when StatusCode is set.
+	Body []byte
+	// Malformed sends the request and cuts the response body in half, so it
+	// is not valid JSON anymore.
+	Malformed bool
+	// Truncate sends the request and fails reading the response body with
+	// io.ErrUnexpectedEOF after this many bytes, like a dropped connection.
+	Truncate int64
+}
+
+// RoundTrip implements http.RoundTripper.
+func (f *Fault) RoundTrip(req *http.Request) (*http.Response, error) {
+	t := transportOr(f.Transport)
+	if (f.Match != nil && !f.Match(req)) || rand.Float64() >= f.Probability {
+		return t.RoundTrip(req)
+	}
+	switch {
+	case f.Err != nil:
+		closeBody(req)
+		return nil, f.Err
+	case f.StatusCode != 0:
+		closeBody(req)
+		return newResponse(req, f.StatusCode, f.Body), nil
+	case f.Malformed:
+		resp, err := t.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		b, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		b = b[:len(b)/2]
+		resp.Body = io.NopCloser(bytes.NewReader(b))
+		resp.ContentLength = int64(len(b))
+		resp.Header.Set("Content-Length", strconv.Itoa(len(b)))
+		return resp, nil
+	case f.Truncate > 0:
+		resp, err := t.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = &truncateReader{ReadCloser: resp.Body, left: f.Truncate}
+		return resp, nil
+	default:
+		closeBody(req)
+		return nil, ErrInjectedFault
+	}
+}
+
+// truncateReader fails with io.ErrUnexpectedEOF once left bytes were read.
+type truncateReader struct {
+	io.ReadCloser
+	left int64
+}
+
+func (t *truncateReader) Read(p []byte) (int, error) {
+	if t.left <= 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	if int64(len(p)) > t.left {
+		p = p[:t.left]
+	}
+	n, err := t.ReadCloser.Read(p)
+	t.left -= int64(n)
+	return n, err
+}
+
+// newResponse returns a response to req generated without sending it.
+func newResponse(req *http.Request, status int, body []byte) *http.Response {
+	resp := &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+	if len(body) != 0 {
+		resp.Header.Set("Content-Type", jsonContentType)
+	}
+	return resp
+}
+
+// closeBody closes the request body, as http.RoundTripper must do even when
+// the request is not sent.
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+}
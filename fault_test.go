@@ -0,0 +1,85 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestFault(t *testing.T) {
+	t.Parallel()
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"output":"0123456789"}`))
+	}))
+	defer ts.Close()
+	errBoom := errors.New("boom")
+	data := []struct {
+		name  string
+		f     Fault
+		calls int32
+		check func(t *testing.T, err error)
+	}{
+		{"none", Fault{Probability: 0, Err: errBoom}, 1, func(t *testing.T, err error) {
+			if err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"unmatched", Fault{Probability: 1, Err: errBoom, Match: func(*http.Request) bool { return false }}, 1, func(t *testing.T, err error) {
+			if err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"err", Fault{Probability: 1, Err: errBoom}, 0, func(t *testing.T, err error) {
+			if !errors.Is(err, errBoom) {
+				t.Fatalf("Unexpected error %v", err)
+			}
+		}},
+		{"default", Fault{Probability: 1}, 0, func(t *testing.T, err error) {
+			if !errors.Is(err, ErrInjectedFault) {
+				t.Fatalf("Unexpected error %v", err)
+			}
+		}},
+		{"status", Fault{Probability: 1, StatusCode: 503, Body: []byte(`{"error":"down"}`)}, 0, func(t *testing.T, err error) {
+			var herr *Error
+			if !errors.As(err, &herr) || herr.StatusCode != 503 || string(herr.ResponseBody) != `{"error":"down"}` {
+				t.Fatalf("Unexpected error %v", err)
+			}
+		}},
+		{"malformed", Fault{Probability: 1, Malformed: true}, 1, func(t *testing.T, err error) {
+			var herr *Error
+			if !errors.As(err, &herr) || string(herr.ResponseBody) != `{"output":"` {
+				t.Fatalf("Unexpected error %v", err)
+			}
+		}},
+		{"truncate", Fault{Probability: 1, Truncate: 5}, 1, func(t *testing.T, err error) {
+			if !errors.Is(err, io.ErrUnexpectedEOF) {
+				t.Fatalf("Unexpected error %v", err)
+			}
+		}},
+	}
+	for _, line := range data {
+		t.Run(line.name, func(t *testing.T) {
+			calls.Store(0)
+			f := line.f
+			c := Client{Client: &http.Client{Transport: &f}}
+			var out struct {
+				Output string `json:"output"`
+			}
+			line.check(t, c.Get(context.Background(), ts.URL, nil, &out))
+			if n := calls.Load(); n != line.calls {
+				t.Errorf("Unexpected\nwant: %v\ngot:  %v", line.calls, n)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// FieldCaseError is a JSON key that matches a struct field only when ignoring
+// case.
+type FieldCaseError struct {
+	StructType string
+	Field      string
+	// Expected is the JSON name of the struct field that matched.
+	Expected string
+}
+
+// Error implements the error interface.
+func (e *FieldCaseError) Error() string {
+	return fmt.Sprintf("field %s.%s has the wrong case, expected %q", e.StructType, e.Field, e.Expected)
+}
+
+// checkFieldCase returns joined *FieldCaseError for all the keys in b that
+// match a field of out only case-insensitively.
+func checkFieldCase(b []byte, out any) error {
+	t := reflect.TypeOf(out)
+	if t == nil {
+		return nil
+	}
+	var v any
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if d.Decode(&v) != nil {
+		// Let the real decoder report the error.
+		return nil
+	}
+	return errors.Join(findFieldCaseErrors(t, t, v, "")...)
+}
+
+func findFieldCaseErrors(root, t reflect.Type, value any, prefix string) []error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if isLeafType(t) {
+		return nil
+	}
+	var out []error
+	switch v := value.(type) {
+	case map[string]any:
+		switch t.Kind() {
+		case reflect.Struct:
+			fields := collectJSONFields(t)
+			for key, vv := range v {
+				p := key
+				if prefix != "" {
+					p = prefix + "." + key
+				}
+				name, ok := fields[key]
+				if !ok {
+					for jsonName, goName := range fields {
+						if strings.EqualFold(jsonName, key) {
+							out = append(out, &FieldCaseError{StructType: root.String(), Field: p, Expected: jsonName})
+							name, ok = goName, true
+							break
+						}
+					}
+				}
+				if ok {
+					f, _ := t.FieldByName(name)
+					out = append(out, findFieldCaseErrors(root, f.Type, vv, p)...)
+				}
+			}
+		case reflect.Map:
+			for key, vv := range v {
+				out = append(out, findFieldCaseErrors(root, t.Elem(), vv, prefix+fmt.Sprintf("[%s]", key))...)
+			}
+		}
+	case []any:
+		if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			for i, vv := range v {
+				out = append(out, findFieldCaseErrors(root, t.Elem(), vv, prefix+fmt.Sprintf("[%d]", i))...)
+			}
+		}
+	}
+	return out
+}
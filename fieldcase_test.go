@@ -0,0 +1,52 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestClient_decode_case_sensitive(t *testing.T) {
+	type Item struct {
+		Name string `json:"name"`
+	}
+	type Out struct {
+		Message string          `json:"message"`
+		Items   []Item          `json:"items"`
+		ByID    map[string]Item `json:"by_id"`
+	}
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"exact", `{"message":"hi","items":[{"name":"a"}],"by_id":{"x":{"name":"b"}}}`, ""},
+		{"root", `{"Message":"hi"}`, "field *httpjson.Out.Message has the wrong case, expected \"message\""},
+		{"nested", `{"items":[{"name":"a"},{"NAME":"b"}]}`, "field *httpjson.Out.items[1].NAME has the wrong case, expected \"name\""},
+		{"map", `{"by_id":{"x":{"Name":"b"}}}`, "field *httpjson.Out.by_id[x].Name has the wrong case, expected \"name\""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Client{CaseSensitive: true}
+			var out Out
+			err := c.decode(context.Background(), []byte(tt.data), &out)
+			if tt.want == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			var ferr *FieldCaseError
+			if !errors.As(err, &ferr) {
+				t.Fatalf("expected FieldCaseError, got %v", err)
+			}
+			if got := err.Error(); got != tt.want {
+				t.Errorf("Unexpected\nwant: %q\ngot:  %q", tt.want, got)
+			}
+		})
+	}
+}
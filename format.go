@@ -0,0 +1,195 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	timeType        = reflect.TypeFor[time.Time]()
+	formatTagsCache sync.Map // map[reflect.Type]bool
+)
+
+// hasFormatTags reports whether t contains a field with a format tag,
+// recursively.
+func hasFormatTags(t reflect.Type) bool {
+	if v, ok := formatTagsCache.Load(t); ok {
+		return v.(bool)
+	}
+	v := hasFormatTagsRecursive(t, map[reflect.Type]bool{})
+	formatTagsCache.Store(t, v)
+	return v
+}
+
+func hasFormatTagsRecursive(t reflect.Type, seen map[reflect.Type]bool) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+	switch t.Kind() {
+	case reflect.Struct:
+		if isLeafType(t) {
+			return false
+		}
+		for i := range t.NumField() {
+			f := t.Field(i)
+			if _, ok := formatTag(f); ok || hasFormatTagsRecursive(f.Type, seen) {
+				return true
+			}
+		}
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return hasFormatTagsRecursive(t.Elem(), seen)
+	}
+	return false
+}
+
+// formatTag returns the format option of the httpjson struct tag.
+func formatTag(f reflect.StructField) (string, bool) {
+	tag := f.Tag.Get("httpjson")
+	for tag != "" {
+		if v, ok := strings.CutPrefix(tag, "format="); ok {
+			return v, true
+		}
+		_, tag, _ = strings.Cut(tag, ",")
+	}
+	return "", false
+}
+
+// applyFormatTags rewrites the values of the fields with a format tag in b as
+// RFC 3339 strings so they can be decoded into time.Time.
+func applyFormatTags(b []byte, t reflect.Type) ([]byte, error) {
+	var v any
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if d.Decode(&v) != nil {
+		// Let the real decoder report the error.
+		return b, nil
+	}
+	v, err := applyFormats(t, v, "")
+	if err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	e := json.NewEncoder(buf)
+	e.SetEscapeHTML(false)
+	if err = e.Encode(v); err != nil {
+		return nil, fmt.Errorf("internal error: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func applyFormats(t reflect.Type, value any, prefix string) (any, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		m, ok := value.(map[string]any)
+		if !ok || isLeafType(t) {
+			return value, nil
+		}
+		fields := collectJSONFields(t)
+		for key, v := range m {
+			name, ok := fields[key]
+			if !ok {
+				continue
+			}
+			f, _ := t.FieldByName(name)
+			p := key
+			if prefix != "" {
+				p = prefix + "." + key
+			}
+			var err error
+			if format, ok := formatTag(f); ok {
+				m[key], err = formatTime(f.Type, format, v, p)
+			} else {
+				m[key], err = applyFormats(f.Type, v, p)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		l, ok := value.([]any)
+		if !ok {
+			return value, nil
+		}
+		for i, v := range l {
+			var err error
+			if l[i], err = applyFormats(t.Elem(), v, prefix+fmt.Sprintf("[%d]", i)); err != nil {
+				return nil, err
+			}
+		}
+	case reflect.Map:
+		m, ok := value.(map[string]any)
+		if !ok {
+			return value, nil
+		}
+		for key, v := range m {
+			var err error
+			if m[key], err = applyFormats(t.Elem(), v, prefix+fmt.Sprintf("[%s]", key)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return value, nil
+}
+
+// formatTime converts value in format into a RFC 3339 string.
+func formatTime(t reflect.Type, format string, value any, field string) (any, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t != timeType {
+		return nil, fmt.Errorf("field %s: format tag requires time.Time, got %s", field, t)
+	}
+	if value == nil {
+		return nil, nil
+	}
+	var ts time.Time
+	switch format {
+	case "unix", "unixmilli":
+		n, ok := value.(json.Number)
+		if !ok {
+			return nil, fmt.Errorf("field %s: expected a number for format %s, got %T", field, format, value)
+		}
+		i, err := strconv.ParseInt(string(n), 10, 64)
+		switch {
+		case err == nil && format == "unix":
+			ts = time.Unix(i, 0)
+		case err == nil:
+			ts = time.UnixMilli(i)
+		case format == "unix":
+			f, err2 := strconv.ParseFloat(string(n), 64)
+			if err2 != nil {
+				return nil, fmt.Errorf("field %s: %w", field, err2)
+			}
+			ts = time.Unix(0, int64(f*float64(time.Second)))
+		default:
+			return nil, fmt.Errorf("field %s: %w", field, err)
+		}
+	default:
+		str, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("field %s: expected a string for format %q, got %T", field, format, value)
+		}
+		var err error
+		if ts, err = time.Parse(format, str); err != nil {
+			return nil, fmt.Errorf("field %s: %w", field, err)
+		}
+	}
+	return ts.UTC().Format(time.RFC3339Nano), nil
+}
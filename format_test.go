@@ -0,0 +1,76 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDecodeJSON_format(t *testing.T) {
+	type Inner struct {
+		Day time.Time `json:"day" httpjson:"format=Mon, 02 Jan 2006"`
+	}
+	type Out struct {
+		Created  time.Time  `json:"created" httpjson:"format=unix"`
+		Fraction time.Time  `json:"fraction" httpjson:"format=unix"`
+		Updated  *time.Time `json:"updated" httpjson:"format=unixmilli"`
+		Missing  *time.Time `json:"missing" httpjson:"format=unix"`
+		Items    []Inner    `json:"items"`
+		Standard time.Time  `json:"standard"`
+	}
+	data := `{"created":1700000000,"fraction":1700000000.5,"updated":1700000000123,"missing":null,` +
+		`"items":[{"day":"Tue, 14 Nov 2023"}],"standard":"2023-11-14T22:13:20Z"}`
+	var out Out
+	if err := decodeJSON([]byte(data), &out, false); err != nil {
+		t.Fatal(err)
+	}
+	want := time.Unix(1700000000, 0)
+	if !out.Created.Equal(want) {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", want, out.Created)
+	}
+	if w := want.Add(500 * time.Millisecond); !out.Fraction.Equal(w) {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", w, out.Fraction)
+	}
+	if w := want.Add(123 * time.Millisecond); out.Updated == nil || !out.Updated.Equal(w) {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", w, out.Updated)
+	}
+	if out.Missing != nil {
+		t.Errorf("Unexpected %v", out.Missing)
+	}
+	if w := time.Date(2023, 11, 14, 0, 0, 0, 0, time.UTC); len(out.Items) != 1 || !out.Items[0].Day.Equal(w) {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", w, out.Items)
+	}
+	if !out.Standard.Equal(want) {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", want, out.Standard)
+	}
+}
+
+func TestDecodeJSON_format_error(t *testing.T) {
+	type Out struct {
+		Created time.Time `json:"created" httpjson:"format=unix"`
+	}
+	type Bad struct {
+		Created string `json:"created" httpjson:"format=unix"`
+	}
+	tests := []struct {
+		name string
+		data string
+		out  any
+		want string
+	}{
+		{"string", `{"created":"now"}`, &Out{}, "field created: expected a number for format unix, got string"},
+		{"type", `{"created":1}`, &Bad{}, "field created: format tag requires time.Time, got string"},
+		{"syntax", `{"created":`, &Out{}, "unexpected EOF"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := decodeJSON([]byte(tt.data), tt.out, false)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("Unexpected\nwant: %v\ngot:  %v", tt.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"context"
+	"reflect"
+	"time"
+)
+
+// Healthcheck polls the JSON health endpoint at url every interval until ctx
+// is canceled, then returns ctx.Err().
+//
+// f is called with the first result then each time the decoded status or the
+// error changes. A response with a status code of 400 or more is reported
+// as an *Error along the status decoded from its body.
+//
+// After a failure the polling interval doubles, up to 32 times interval,
+// and is reset on the next success.
+func Healthcheck[T any](ctx context.Context, c *Client, url string, interval time.Duration, f func(status T, err error)) error {
+	first := true
+	var last T
+	var lastErr error
+	failures := 0
+	for {
+		status, err := pollHealth[T](ctx, c, url)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if first || !reflect.DeepEqual(status, last) || !sameError(err, lastErr) {
+			f(status, err)
+			first = false
+			last = status
+			lastErr = err
+		}
+		wait := interval
+		if err != nil {
+			failures = min(failures+1, 5)
+			wait <<= failures
+		} else {
+			failures = 0
+		}
+		if err := clockOr(c.Clock).Sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+func pollHealth[T any](ctx context.Context, c *Client, url string) (T, error) {
+	var status T
+	resp, err := c.GetRequest(ctx, url, nil)
+	if err != nil {
+		return status, err
+	}
+	if err = c.decodeResponse(resp, &status); err != nil {
+		return status, err
+	}
+	if resp.StatusCode >= 400 {
+		return status, newError(resp, nil, false)
+	}
+	return status, nil
+}
+
+func sameError(a, b error) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Error() == b.Error()
+}
@@ -0,0 +1,69 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHealthcheck(t *testing.T) {
+	t.Parallel()
+	// Sequence of responses; the last one repeats.
+	seq := []int{200, 200, 503, 503, 200}
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		code := seq[min(int(calls.Add(1))-1, len(seq)-1)]
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(code)
+		if code == 200 {
+			_, _ = w.Write([]byte(`{"status":"ok"}`))
+		} else {
+			_, _ = w.Write([]byte(`{"status":"down"}`))
+		}
+	}))
+	defer ts.Close()
+	type health struct {
+		Status string `json:"status"`
+	}
+	type result struct {
+		status string
+		code   int
+	}
+	var got []result
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err := Healthcheck(ctx, &Client{}, ts.URL, time.Millisecond, func(h health, err error) {
+		r := result{status: h.Status}
+		if err != nil {
+			var herr *Error
+			if !errors.As(err, &herr) {
+				t.Errorf("Unexpected error: %v", err)
+			} else {
+				r.code = herr.StatusCode
+			}
+		}
+		got = append(got, r)
+		if len(got) == 3 {
+			cancel()
+		}
+	})
+	if err != context.Canceled {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", context.Canceled, err)
+	}
+	want := []result{{"ok", 0}, {"down", 503}, {"ok", 0}}
+	if !slices.Equal(got, want) {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", want, got)
+	}
+	if c := calls.Load(); c != 5 {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", 5, c)
+	}
+}
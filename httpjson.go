@@ -3,21 +3,55 @@
 // that can be found in the LICENSE file.
 
 // Package httpjson is a deceptively simple JSON REST HTTP client.
+//
+// # Time formats
+//
+// time.Time fields can be decoded from nonstandard representations with a
+// struct tag:
+//
+//	Created time.Time `json:"created" httpjson:"format=unix"`
+//	Updated time.Time `json:"updated" httpjson:"format=unixmilli"`
+//	Day     time.Time `json:"day" httpjson:"format=2006-01-02"`
+//
+// "unix" accepts seconds since epoch, with an optional fractional part.
+// "unixmilli" accepts milliseconds since epoch. Any other value is a layout
+// passed to time.Parse(). The format option must be the last one in the tag
+// since a layout may contain commas.
 package httpjson
 
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"html"
 	"io"
+	"mime"
+	"net"
 	"net/http"
+	"net/url"
 	"reflect"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/maruel/httpjson/retry"
 )
 
 // Client is a JSON REST HTTP client using good default behavior.
+//
+// The zero value is ready to use. The concurrency limit, the in-flight
+// coalesced requests and the Stats are created on first use; copies of a
+// Client made afterward share them.
 type Client struct {
 	// Client defaults to http.DefaultClient. Override with http.RoundTripper to
 	// add functionality. See github.com/maruel/roundtrippers for useful ones
@@ -31,19 +65,283 @@ type Client struct {
 	// Use this in production so that your client doesn't break when the server
 	// add new fields.
 	Lenient bool
+	// StrictNumbers reports numbers that do not fit their destination field as
+	// *NumberError: integer overflow, a fractional number going into an
+	// integer or precision loss when going into a float.
+	//
+	// All the faulty fields are reported, not only the first one.
+	StrictNumbers bool
+	// DisallowDuplicateKeys reports keys that appear more than once in the
+	// same JSON object as *DuplicateKeyError. encoding/json silently keeps the
+	// last one, which can be abused to smuggle values past validation. Keys
+	// decoded into a struct are compared case-insensitively, like
+	// encoding/json matches them to fields.
+	DisallowDuplicateKeys bool
+	// CaseSensitive requires JSON keys to match the case of the struct fields
+	// exactly. encoding/json matches them case-insensitively, which can hide
+	// server side casing bugs. Near misses are reported as *FieldCaseError.
+	CaseSensitive bool
+	// DisallowNull reports a JSON null decoded into a field that cannot hold
+	// it, like an int or a struct, as *NullFieldError. encoding/json silently
+	// leaves the zero value, making null indistinguishable from zero. Use a
+	// pointer for fields that can legitimately be null.
+	DisallowNull bool
+	// Relaxed strips comments and trailing commas from the response before
+	// decoding it, for endpoints that emit not-quite-JSON. This is independent
+	// from Lenient.
+	Relaxed bool
+	// SnakeCase maps snake_case JSON keys to struct fields without a json tag
+	// name, e.g. "user_id" to UserID, and encodes these fields back as
+	// snake_case in requests. Fields with a json tag name are left untouched.
+	SnakeCase bool
+	// DisallowRedirects returns an *Error with the Location header instead of
+	// following redirects. For APIs, a redirect to a login page usually means
+	// the credentials expired and following it hides the real problem.
+	DisallowRedirects bool
+	// OnRequest hooks are called in order right before each request is sent.
+	// Returning an error aborts the request.
+	//
+	// This is a lightweight alternative to writing an http.RoundTripper for
+	// small cross-cutting tweaks.
+	OnRequest []func(*http.Request) error
+	// OnResponse hooks are called in order right after each response is
+	// received. Returning an error closes the response body and aborts the
+	// request.
+	OnResponse []func(*http.Response) error
+	// UploadProgress is called as the request body is being sent. total is -1
+	// when the length is unknown.
+	UploadProgress func(sent, total int64)
+	// DownloadProgress is called as the response body is being read. total is
+	// -1 when the server didn't specify Content-Length.
+	DownloadProgress func(received, total int64)
+	// MaxConcurrent limits the number of requests in flight at once when
+	// greater than 0. Additional requests wait for a slot until their context
+	// is canceled.
+	//
+	// A request is in flight until its response body is closed. Waiting
+	// requests are sent by priority, see WithPriority.
+	MaxConcurrent int
+	// IdempotencyKey adds a random UUID "Idempotency-Key" header to POST and
+	// PATCH requests that do not already have one.
+	//
+	// The header is set on the request itself so retries of the same
+	// *http.Request, for example by a retrying http.RoundTripper, reuse the
+	// same key.
+	IdempotencyKey bool
+	// DumpDir, when set, is a directory where the full request and response of
+	// failed calls to Get and Post are written, one timestamped file per
+	// exchange. A call fails when the status code is >= 400 or the response
+	// cannot be decoded.
+	//
+	// Secrets in well known headers, URL query parameters and secret-named
+	// fields of JSON bodies are redacted. Non-JSON bodies are written as is.
+	// Writing is best effort; failures to write are ignored.
+	DumpDir string
+	// Observer is notified after every request, independently of the
+	// transports used. Use it to feed latency histograms.
+	Observer Observer
+	// RetryOn429 is the maximum number of times a request is retried when the
+	// server replies with 429 Too Many Requests and a Retry-After header. The
+	// client waits as instructed, unless the wait would exceed the context
+	// deadline, in which case the 429 response is returned as is. A 429 means
+	// the request was not processed, so requests of any method are retried.
+	//
+	// Requests with a body are only retried if the body can be replayed, see
+	// Build().
+	RetryOn429 int
+	// Retry decides whether to retry a request after a response or a
+	// transport error, and how long to wait, see the retry package. It is
+	// consulted when RetryOn429 doesn't retry.
+	//
+	// Only requests safe to send twice are retried, see retry.Idempotent:
+	// set IdempotencyKey to retry POST and PATCH requests. Like for
+	// RetryOn429, requests with a body are only retried if the body can be
+	// replayed.
+	Retry retry.Policy
+	// RetryMethods overrides the methods Retry applies to, see
+	// retry.Methods. Requests with an Idempotency-Key header are retried
+	// independently of their method. Defaults to GET, HEAD, OPTIONS and TRACE.
+	RetryMethods []string
+	// RetryBudget, when set, bounds the retries done by RetryOn429 and Retry
+	// across all the requests of the client. When the budget is exhausted,
+	// the request fails with a *retry.BudgetError instead of being retried.
+	// Its Err is the *Error of the last response when its status code is 400
+	// or higher.
+	RetryBudget *retry.Budget
+	// Clock is used to wait between retries and by Healthcheck. Defaults to
+	// the real time.
+	Clock Clock
+	// ExpectContinueSize, when greater than 0, adds an "Expect: 100-continue"
+	// header to requests with a body of at least this many bytes, or of
+	// unknown length. The server can then reject the request, e.g. with a 401,
+	// before the body is uploaded.
+	//
+	// It requires the transport's ExpectContinueTimeout to be set, which is
+	// the case for http.DefaultTransport.
+	ExpectContinueSize int64
+	// Cache, when set, memoizes the successful response bodies of Get calls.
+	// It is meant for configuration or metadata endpoints that are polled
+	// frequently and does not implement HTTP caching semantics. Use
+	// WithFreshness to know whether a value came from the cache.
+	Cache *Cache
+	// Coalesce shares a single request between concurrent Get calls for the
+	// same URL and headers. The request uses the context of the first caller
+	// and OnResponse hooks run once.
+	Coalesce bool
+	// DenyHeaders lists request headers removed before a request is sent, for
+	// example internal authentication headers that must not be forwarded to
+	// a third party.
+	DenyHeaders []string
+	// AllowHeaders, when not empty, lists the only request headers that are
+	// sent; all others are removed. Headers added by the transport itself,
+	// like Host, User-Agent or Accept-Encoding, are not affected.
+	AllowHeaders []string
+	// RequireHTTPS refuses to send requests, including redirects, to plain
+	// http:// URLs, returning an *InsecureURLError. It protects credentials
+	// from being sent in clear text because of a mistyped base URL.
+	RequireHTTPS bool
+	// InsecureHosts are the hosts still allowed over plain http when
+	// RequireHTTPS is set. When nil, it defaults to loopback hosts:
+	// "localhost" and loopback IPs. Set it to an empty slice to refuse them
+	// too.
+	InsecureHosts []string
+	// MaxResponseSize, when greater than 0, is the maximum size of a response
+	// body. A response with a larger Content-Length is refused before its body
+	// is read, and reading a body of unknown length fails once it grows past
+	// the limit. Both return a *ResponseTooLargeError.
+	MaxResponseSize int64
+	// VerifyDigest checks the response body against the sha-256 or md5
+	// digest in the Content-Digest, Digest or Content-MD5 header, if any.
+	// Reading the end of a mismatching body returns a *DigestError.
+	VerifyDigest bool
+	// BodySHA256, when set, is called with the SHA-256 of each response body
+	// once it is fully read, for clients that must audit exactly what was
+	// received.
+	BodySHA256 func(resp *http.Response, sum [sha256.Size]byte)
+	// WireLog, when set, receives one JSON line per exchange, as a
+	// WireLogEntry, once the response body is closed or the request failed.
+	WireLog io.Writer
+	// Accept is the Accept request header. It defaults to "application/json".
+	// It doesn't override an Accept header already set on a request passed to
+	// Do.
+	Accept string
+	// RequireJSON fails decoding a non-empty response whose Content-Type is
+	// not JSON, "application/json" or a "+json" suffix, with a
+	// *ContentTypeError. It gives a clearer failure than a JSON syntax error
+	// when a proxy returns an HTML or plain text page.
+	RequireJSON bool
+	// AlwaysSendContentType sends the Content-Type header even on requests
+	// without a body, for the rare API requiring it.
+	AlwaysSendContentType bool
+	// ContentType is the Content-Type of JSON request bodies. It defaults to
+	// "application/json; charset=utf-8". Use it for media types like
+	// "application/merge-patch+json" or vendor specific ones. To change it for
+	// a single call, pass a Content-Type header in hdr.
+	ContentType string
+	// DisallowEmptyBody returns ErrEmptyBody on a successful response with an
+	// empty body, like a 204 No Content. By default, out is left unchanged and
+	// no error is returned. An empty body with an error status code always
+	// returns ErrEmptyBody.
+	DisallowEmptyBody bool
+	// Marshal replaces encoding/json to encode request bodies, e.g. with
+	// github.com/goccy/go-json for high throughput clients. It should not
+	// escape HTML characters, like the default.
+	Marshal func(v any) ([]byte, error)
+	// Unmarshal replaces encoding/json to decode response bodies. Unless
+	// Lenient is set, unknown fields are still reported: the body is decoded a
+	// second time into a map[string]any with Unmarshal and compared to the
+	// output type with FindExtraKeys.
+	Unmarshal func(data []byte, v any) error
+	// StreamDecode decodes successful responses straight from the body
+	// instead of reading it in memory first, keeping memory use constant for
+	// multi-megabyte responses. The trade off is that the body is not
+	// included in the returned *Error and unknown fields are reported as a
+	// single plain error from encoding/json.
+	//
+	// Responses with status 400 or higher, non UTF-8 charsets and the options
+	// needing the whole body (Relaxed, SnakeCase, DisallowDuplicateKeys,
+	// CaseSensitive, StrictNumbers, DisallowNull, DumpDir, Unmarshal, Cache)
+	// are still buffered.
+	StreamDecode bool
+
+	state atomic.Pointer[clientState]
+	_     struct{}
+}
 
-	_ struct{}
+// clientState is the mutable state of a Client. It lives behind a pointer so
+// copies of a Client never share a map without the mutex guarding it.
+type clientState struct {
+	mu      sync.Mutex
+	sem     *semaphore
+	flights map[string]*flight
+	stats   clientStats
+	// wireMu serializes the writes to WireLog, separately from mu so a slow
+	// writer doesn't block other requests.
+	wireMu sync.Mutex
+}
+
+// shared returns the client's state, creating it on first use.
+func (c *Client) shared() *clientState {
+	if s := c.state.Load(); s != nil {
+		return s
+	}
+	c.state.CompareAndSwap(nil, &clientState{})
+	return c.state.Load()
 }
 
 // DefaultClient uses http.DefaultClient and refuses unknown fields, returning *UnknownFieldError on them.
 var DefaultClient = Client{}
 
+// NewHTTP2Client returns a Client whose transport only speaks HTTP/2 over
+// TLS, with timeouts suitable for API clients.
+//
+// Requests to plain http:// URLs fail with this client.
+func NewHTTP2Client() *Client {
+	t := NewTransport()
+	t.Protocols = &http.Protocols{}
+	t.Protocols.SetHTTP2(true)
+	return &Client{Client: &http.Client{Transport: t}}
+}
+
+// NewTransport returns an http.Transport with connection level timeouts
+// suitable for API clients, speaking HTTP/1.1 and HTTP/2 over http and https.
+// Use it as the Transport of Client.Client.
+//
+// There is no overall timeout since API calls can legitimately be long; use
+// the context for that.
+func NewTransport() *http.Transport {
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   16,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 5 * time.Minute,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
 // Get simplifies doing an HTTP GET in JSON. Returns *Error on failure.
 //
 // It fails on unknown fields in the response, returning *UnknownFieldError on them.
 //
 // Buffers response body in memory.
 func (c *Client) Get(ctx context.Context, url string, hdr http.Header, out any) error {
+	if c.Cache != nil {
+		return c.cachedGet(ctx, url, hdr, out)
+	}
+	if c.Coalesce {
+		resp, b, err := c.fetch(ctx, url, hdr)
+		if err != nil {
+			return err
+		}
+		return c.decodeBody(resp, b, out)
+	}
 	resp, err := c.GetRequest(ctx, url, hdr)
 	if err != nil {
 		return err
@@ -51,6 +349,48 @@ func (c *Client) Get(ctx context.Context, url string, hdr http.Header, out any)
 	return c.decodeResponse(resp, out)
 }
 
+// GetOptional is like Get but a 404 Not Found returns false and no error
+// instead of decoding the body, for lookups of resources that may be missing.
+func (c *Client) GetOptional(ctx context.Context, url string, hdr http.Header, out any) (bool, error) {
+	resp, err := c.GetRequest(ctx, url, hdr)
+	if err != nil {
+		return false, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return false, resp.Body.Close()
+	}
+	return true, c.decodeResponse(resp, out)
+}
+
+// GetBytes does an HTTP GET and returns the response body without decoding
+// it, for endpoints that do not always return JSON.
+//
+// A status code of 400 or more returns an *Error along the body.
+func (c *Client) GetBytes(ctx context.Context, url string, hdr http.Header) ([]byte, error) {
+	resp, err := c.GetRequest(ctx, url, hdr)
+	if err != nil {
+		return nil, err
+	}
+	b, err := readBody(resp)
+	if err != nil {
+		return b, err
+	}
+	if resp.StatusCode >= 400 {
+		if c.DumpDir != "" {
+			_ = dumpExchange(c.DumpDir, resp, b)
+		}
+		return b, newError(resp, b, true)
+	}
+	return b, nil
+}
+
+// GetString is like GetBytes but returns a string.
+func (c *Client) GetString(ctx context.Context, url string, hdr http.Header) (string, error) {
+	b, err := c.GetBytes(ctx, url, hdr)
+	return string(b), err
+}
+
 // GetRequest simplifies doing an HTTP POST in JSON. Returns *Error on failure.
 //
 // It is a shorthand for Request().
@@ -64,6 +404,9 @@ func (c *Client) GetRequest(ctx context.Context, url string, hdr http.Header) (*
 //
 // It fails on unknown fields in the response, returning *UnknownFieldError on them.
 //
+// In of type json.RawMessage, []byte or io.Reader is sent as-is, assuming it
+// is already encoded.
+//
 // Buffers both post data and response body in memory.
 func (c *Client) Post(ctx context.Context, url string, hdr http.Header, in, out any) error {
 	resp, err := c.PostRequest(ctx, url, hdr, in)
@@ -85,34 +428,392 @@ func (c *Client) PostRequest(ctx context.Context, url string, hdr http.Header, i
 	return c.Request(ctx, "POST", url, hdr, in)
 }
 
+// Head does an HTTP HEAD and returns the status code and headers.
+//
+// No error is returned for HTTP error status codes, making it suitable for
+// existence checks.
+func (c *Client) Head(ctx context.Context, url string, hdr http.Header) (int, http.Header, error) {
+	return c.statusRequest(ctx, "HEAD", url, hdr)
+}
+
+// Options does an HTTP OPTIONS and returns the status code and headers.
+//
+// No error is returned for HTTP error status codes, making it suitable for
+// CORS and capability probing.
+func (c *Client) Options(ctx context.Context, url string, hdr http.Header) (int, http.Header, error) {
+	return c.statusRequest(ctx, "OPTIONS", url, hdr)
+}
+
+func (c *Client) statusRequest(ctx context.Context, method, url string, hdr http.Header) (int, http.Header, error) {
+	resp, err := c.Request(ctx, method, url, hdr, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if err = resp.Body.Close(); err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode, resp.Header, nil
+}
+
 // Request simplifies doing an HTTP PATCH/DELETE/PUT in JSON.
 //
-// In is optional.
+// In is optional. In of type json.RawMessage, []byte or io.Reader is sent
+// as-is, assuming it is already encoded.
 //
 // It initiates the requests and returns the response back for further processing.
 // Buffers post data in memory.
 func (c *Client) Request(ctx context.Context, method, url string, hdr http.Header, in any) (*http.Response, error) {
+	req, err := c.Build(ctx, method, url, hdr, in)
+	if err != nil {
+		return nil, err
+	}
+	return c.do(req)
+}
+
+// Build returns the fully prepared *http.Request without sending it.
+//
+// In is optional. The body is encoded and the headers are merged exactly like
+// Request() does. In of type json.RawMessage, []byte or io.Reader is sent
+// as-is, assuming it is already encoded. This is an escape hatch to hand the
+// request to a custom executor, like a scheduler or a test harness.
+//
+// The Idempotency-Key and Expect headers are added, DenyHeaders and
+// AllowHeaders are applied and RequireHTTPS is checked, like when sending.
+// OnRequest hooks are not called since they run right before each attempt.
+//
+// The request's GetBody is set so the body can be replayed on redirects and by
+// retrying transports, except when in is an io.Reader other than
+// *bytes.Buffer, *bytes.Reader or *strings.Reader.
+//
+// Buffers post data in memory.
+func (c *Client) Build(ctx context.Context, method, url string, hdr http.Header, in any) (*http.Request, error) {
 	var b io.Reader
-	if in != nil {
-		buf := &bytes.Buffer{}
-		e := json.NewEncoder(buf)
-		// OMG this took me a while to figure this out. This affects LLM token encoding.
-		e.SetEscapeHTML(false)
-		if err := e.Encode(in); err != nil {
+	switch v := in.(type) {
+	case nil:
+	case json.RawMessage:
+		b = bytes.NewReader(v)
+	case []byte:
+		b = bytes.NewReader(v)
+	case io.Reader:
+		b = v
+	default:
+		data, err := c.marshal(in)
+		if err != nil {
 			return nil, fmt.Errorf("internal error: %w", err)
 		}
-		b = buf
+		if c.SnakeCase {
+			if data, err = renameKeysJSON(data, reflect.TypeOf(in), false); err != nil {
+				return nil, err
+			}
+		}
+		// http.NewRequestWithContext sets GetBody for *bytes.Reader.
+		b = bytes.NewReader(data)
 	}
 	req, err := http.NewRequestWithContext(ctx, method, url, b)
 	if err != nil {
 		return nil, err
 	}
-	return c.Do(req, hdr)
+	c.setHeaders(req, c.contentType(), hdr)
+	if err = c.prepare(req); err != nil {
+		return nil, err
+	}
+	return req, nil
 }
 
 // Do sets the correct headers and allow adding per-request headers.
 func (c *Client) Do(req *http.Request, hdr http.Header) (*http.Response, error) {
-	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	c.setHeaders(req, c.contentType(), hdr)
+	return c.do(req)
+}
+
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	release, err := c.acquire(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	getBody := req.GetBody
+	replayable := req.Body == nil || req.Body == http.NoBody || getBody != nil
+	if c.RetryBudget != nil {
+		c.RetryBudget.Deposit()
+	}
+	for attempt := 1; ; attempt++ {
+		resp, err := c.send(req, attempt)
+		d, again := time.Duration(0), false
+		if replayable {
+			if err == nil && attempt <= c.RetryOn429 {
+				d, again = retryAfter429(req, attempt, resp, nil)
+			}
+			if !again && c.Retry != nil {
+				p := retry.Idempotent(c.Retry)
+				if len(c.RetryMethods) != 0 {
+					p = retry.Methods(c.Retry, c.RetryMethods...)
+				}
+				d, again = p(req, attempt, resp, err)
+			}
+		}
+		if err != nil && !again {
+			release()
+			return resp, err
+		}
+		if !again {
+			if w, ok := req.Context().Value(teeKey{}).(io.Writer); ok {
+				resp.Body = &teeReadCloser{ReadCloser: resp.Body, w: w}
+			}
+			if c.MaxConcurrent > 0 {
+				resp.Body = &releaseCloser{ReadCloser: resp.Body, release: release}
+			}
+			return resp, nil
+		}
+		if c.RetryBudget != nil && !c.RetryBudget.Withdraw() {
+			release()
+			berr := &retry.BudgetError{Attempts: attempt, Err: err}
+			if resp != nil {
+				berr.StatusCode = resp.StatusCode
+				// Keep the last response available as an *Error.
+				b, rerr := readBody(resp)
+				if rerr != nil {
+					berr.Err = rerr
+				} else if resp.StatusCode >= 400 {
+					berr.Err = newError(resp, b, true)
+				}
+			}
+			return nil, berr
+		}
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+		if err = clockOr(c.Clock).Sleep(req.Context(), d); err != nil {
+			release()
+			return nil, err
+		}
+		if getBody != nil {
+			if req.Body, err = getBody(); err != nil {
+				release()
+				return nil, err
+			}
+			req.GetBody = getBody
+		}
+		c.shared().stats.retry()
+	}
+}
+
+// retryAfter429 returns the delay to wait before retrying if resp is a 429
+// with a valid Retry-After header and the delay fits in the context deadline.
+var retryAfter429 = retry.Deadline(retry.RetryAfter(http.StatusTooManyRequests))
+
+// acquire waits for a slot when MaxConcurrent is set. The returned function
+// must be called to release the slot.
+func (c *Client) acquire(ctx context.Context) (func(), error) {
+	if c.MaxConcurrent <= 0 {
+		return func() {}, nil
+	}
+	s := c.shared()
+	s.mu.Lock()
+	if s.sem == nil {
+		s.sem = &semaphore{size: c.MaxConcurrent}
+	}
+	sem := s.sem
+	s.mu.Unlock()
+	if err := sem.acquire(ctx, priorityFrom(ctx)); err != nil {
+		return nil, err
+	}
+	return sem.release, nil
+}
+
+// prepare adds the headers derived from the client's options, filters the
+// headers and checks the URL. It is safe to call more than once.
+func (c *Client) prepare(req *http.Request) error {
+	if c.IdempotencyKey && (req.Method == http.MethodPost || req.Method == http.MethodPatch) && req.Header.Get("Idempotency-Key") == "" {
+		req.Header.Set("Idempotency-Key", newUUID())
+	}
+	if c.ExpectContinueSize > 0 && req.Body != nil && req.Body != http.NoBody && (req.ContentLength < 0 || req.ContentLength >= c.ExpectContinueSize) {
+		req.Header.Set("Expect", "100-continue")
+	}
+	c.filterHeaders(req.Header)
+	return c.checkScheme(req.URL)
+}
+
+func (c *Client) send(req *http.Request, attempt int) (*http.Response, error) {
+	if err := c.prepare(req); err != nil {
+		return nil, err
+	}
+	if len(c.OnRequest) != 0 {
+		for _, h := range c.OnRequest {
+			if err := h(req); err != nil {
+				return nil, err
+			}
+		}
+		// Prepared again since the hooks may add headers or rewrite the URL.
+		if err := c.prepare(req); err != nil {
+			return nil, err
+		}
+	}
+	if c.UploadProgress != nil && req.Body != nil && req.Body != http.NoBody {
+		req.Body = &progressReader{ReadCloser: req.Body, total: req.ContentLength, f: c.UploadProgress}
+		if getBody := req.GetBody; getBody != nil {
+			// Keep reporting progress when the body is replayed.
+			req.GetBody = func() (io.ReadCloser, error) {
+				r, err := getBody()
+				if err != nil {
+					return nil, err
+				}
+				return &progressReader{ReadCloser: r, total: req.ContentLength, f: c.UploadProgress}, nil
+			}
+		}
+	}
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if c.DisallowRedirects {
+		cc := *client
+		cc.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+		client = &cc
+	} else if c.RequireHTTPS {
+		cc := *client
+		check := cc.CheckRedirect
+		cc.CheckRedirect = func(r *http.Request, via []*http.Request) error {
+			if err := c.checkScheme(r.URL); err != nil {
+				return err
+			}
+			if check != nil {
+				return check(r, via)
+			}
+			// Same as http.Client's default policy.
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return nil
+		}
+		client = &cc
+	}
+	c.shared().stats.request(req)
+	start := time.Now()
+	resp, err := client.Do(req)
+	if c.Observer != nil {
+		status := 0
+		if err == nil {
+			status = resp.StatusCode
+		}
+		c.Observer.ObserveRequest(req.Method, req.URL.Host, status, time.Since(start), attempt)
+	}
+	if err != nil {
+		c.shared().stats.transportError()
+		if c.WireLog != nil {
+			e := newWireLogEntry(req, start)
+			e.Duration = time.Since(start)
+			e.Total = e.Duration
+			e.Error = err.Error()
+			c.writeWireLog(e)
+		}
+		return resp, err
+	}
+	c.shared().stats.response(resp)
+	if c.MaxResponseSize > 0 {
+		if resp.ContentLength > c.MaxResponseSize {
+			_ = resp.Body.Close()
+			return nil, &ResponseTooLargeError{Size: resp.ContentLength, Limit: c.MaxResponseSize}
+		}
+		resp.Body = &limitReader{ReadCloser: resp.Body, limit: c.MaxResponseSize}
+	}
+	resp.Body = &countingReader{ReadCloser: resp.Body, s: &c.shared().stats}
+	if c.VerifyDigest || c.BodySHA256 != nil {
+		resp.Body = newDigestReader(resp, c.VerifyDigest, c.BodySHA256)
+	}
+	if c.WireLog != nil {
+		e := newWireLogEntry(req, start)
+		e.Status = resp.StatusCode
+		e.Duration = time.Since(start)
+		resp.Body = &wireLogReader{ReadCloser: resp.Body, c: c, e: e, start: start}
+	}
+	if c.DownloadProgress != nil {
+		resp.Body = &progressReader{ReadCloser: resp.Body, total: resp.ContentLength, f: c.DownloadProgress}
+	}
+	if c.DisallowRedirects && resp.StatusCode >= 300 && resp.StatusCode < 400 && resp.StatusCode != http.StatusNotModified {
+		b, _ := readBody(resp)
+		herr := newError(resp, b, false)
+		herr.Location = resp.Header.Get("Location")
+		return nil, herr
+	}
+	for _, h := range c.OnResponse {
+		if err = h(resp); err != nil {
+			_ = resp.Body.Close()
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// checkScheme enforces RequireHTTPS.
+func (c *Client) checkScheme(u *url.URL) error {
+	if !c.RequireHTTPS || u.Scheme != "http" {
+		return nil
+	}
+	host := u.Hostname()
+	if c.InsecureHosts == nil {
+		if host == "localhost" {
+			return nil
+		}
+		if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+			return nil
+		}
+	} else if slices.Contains(c.InsecureHosts, host) {
+		return nil
+	}
+	return &InsecureURLError{URL: redactURL(u)}
+}
+
+// filterHeaders applies DenyHeaders and AllowHeaders.
+func (c *Client) filterHeaders(h http.Header) {
+	for _, k := range c.DenyHeaders {
+		h.Del(k)
+	}
+	if len(c.AllowHeaders) == 0 {
+		return
+	}
+	for k := range h {
+		if !slices.ContainsFunc(c.AllowHeaders, func(a string) bool { return strings.EqualFold(a, k) }) {
+			delete(h, k)
+		}
+	}
+}
+
+// newUUID returns a random version 4 UUID.
+func newUUID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+const jsonContentType = "application/json; charset=utf-8"
+
+func (c *Client) contentType() string {
+	if c.ContentType != "" {
+		return c.ContentType
+	}
+	return jsonContentType
+}
+
+// setHeaders sets the Content-Type and Accept headers then merges hdr into
+// the request headers.
+//
+// Content-Type is only set when there is a body unless AlwaysSendContentType
+// is set.
+func (c *Client) setHeaders(req *http.Request, contentType string, hdr http.Header) {
+	if c.AlwaysSendContentType || (req.Body != nil && req.Body != http.NoBody) {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if req.Header.Get("Accept") == "" {
+		accept := c.Accept
+		if accept == "" {
+			accept = "application/json"
+		}
+		req.Header.Set("Accept", accept)
+	}
 	for k, v := range hdr {
 		switch len(v) {
 		case 0:
@@ -125,11 +826,56 @@ func (c *Client) Do(req *http.Request, hdr http.Header) (*http.Response, error)
 			}
 		}
 	}
-	client := c.Client
-	if client == nil {
-		client = http.DefaultClient
+}
+
+// releaseCloser releases a concurrency slot once the body is closed.
+type releaseCloser struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (r *releaseCloser) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(r.release)
+	return err
+}
+
+// limitReader fails reads past MaxResponseSize.
+type limitReader struct {
+	io.ReadCloser
+	limit int64
+	read  int64
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	// Read one more byte than allowed to detect an oversized body.
+	if left := l.limit - l.read + 1; int64(len(p)) > left {
+		p = p[:left]
+	}
+	n, err := l.ReadCloser.Read(p)
+	l.read += int64(n)
+	if l.read > l.limit {
+		return n - int(l.read-l.limit), &ResponseTooLargeError{Size: -1, Limit: l.limit}
 	}
-	return client.Do(req)
+	return n, err
+}
+
+// progressReader reports the number of bytes read so far.
+type progressReader struct {
+	io.ReadCloser
+	n     int64
+	total int64
+	f     func(n, total int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.ReadCloser.Read(b)
+	if n > 0 {
+		p.n += int64(n)
+		p.f(p.n, p.total)
+	}
+	return n, err
 }
 
 // DecodeResponse parses the response body as JSON, trying strict decoding for
@@ -141,46 +887,330 @@ func (c *Client) Do(req *http.Request, hdr http.Header) (*http.Response, error)
 // *json.InvalidUnmarshalError) and HTTP status code (*Error). Returns
 // -1 as the index if no output was decoded.
 //
+// Wrap an output with Lenient() to accept unknown fields for this candidate
+// only.
+//
 // Buffers response body in memory.
 func DecodeResponse(resp *http.Response, out ...any) (int, error) {
-	res := -1
-	b, err := io.ReadAll(resp.Body)
-	if err2 := resp.Body.Close(); err == nil {
-		err = err2
+	i, _, err := DecodeResponseBody(resp, out...)
+	return i, err
+}
+
+// DecodeResponse2 is a type safe DecodeResponse with two candidates.
+//
+// Exactly one of the returned pointers is non-nil if decoding succeeded. The
+// error is the same as DecodeResponse.
+func DecodeResponse2[A, B any](resp *http.Response) (*A, *B, error) {
+	a, b := new(A), new(B)
+	switch i, err := DecodeResponse(resp, a, b); i {
+	case 0:
+		return a, nil, err
+	case 1:
+		return nil, b, err
+	default:
+		return nil, nil, err
 	}
+}
+
+// DecodeResponse3 is a type safe DecodeResponse with three candidates.
+//
+// Exactly one of the returned pointers is non-nil if decoding succeeded. The
+// error is the same as DecodeResponse.
+func DecodeResponse3[A, B, C any](resp *http.Response) (*A, *B, *C, error) {
+	a, b, c := new(A), new(B), new(C)
+	switch i, err := DecodeResponse(resp, a, b, c); i {
+	case 0:
+		return a, nil, nil, err
+	case 1:
+		return nil, b, nil, err
+	case 2:
+		return nil, nil, c, err
+	default:
+		return nil, nil, nil, err
+	}
+}
+
+// DecodeResponseBody is like DecodeResponse but also returns the raw response
+// body, even on success.
+//
+// This is useful to persist the raw payload, hash it or decode it again later
+// without issuing a second request.
+func DecodeResponseBody(resp *http.Response, out ...any) (int, []byte, error) {
+	res := -1
+	raw, b, err := readJSONBody(resp)
 	if err != nil {
-		return res, fmt.Errorf("failed to read server response: %w", err)
+		return res, raw, err
 	}
-	var errs []error
+	var errs, decodeErrs []error
 	for i := range out {
-		if err = decodeJSON(b, out[i], false); err == nil {
+		if err = decodeOutput(b, out[i]); err == nil {
 			res = i
 			break
 		}
-		errs = append(errs, fmt.Errorf("failed to decode server response option #%d: %w", i, err))
+		decodeErrs = append(decodeErrs, err)
+	}
+	if res == -1 && len(decodeErrs) != 0 {
+		errs = append(errs, newDecodeError(decodeErrs))
+	} else {
+		// A fallback output was used, report why the previous ones didn't fit.
+		for i, err := range decodeErrs {
+			errs = append(errs, fmt.Errorf("failed to decode server response option #%d: %w", i, err))
+		}
 	}
 	if len(errs) != 0 || resp.StatusCode >= 400 {
 		// Include the body in case of error so the user can diagnose.
-		errs = append(errs, &Error{ResponseBody: b, StatusCode: resp.StatusCode, Status: resp.Status, PrintBody: len(errs) != 0})
+		herr := newError(resp, b, len(errs) != 0)
+		if res != -1 {
+			herr.Decoded = unwrapOutput(out[res])
+		}
+		errs = append(errs, herr)
 	}
-	return res, errors.Join(errs...)
+	return res, raw, errors.Join(errs...)
 }
 
-func (c *Client) decodeResponse(resp *http.Response, out any) error {
-	b, err := io.ReadAll(resp.Body)
+// DecodeResponseByStatus parses the response body as JSON into the output
+// keyed by the HTTP status code, falling back to def when the status code is
+// not in outs. It then closes the response body.
+//
+// Use this instead of DecodeResponse when success and error payloads share
+// fields, making "first one that parses" ambiguous. Outputs can be wrapped
+// with Lenient().
+//
+// If def is nil and no output matches, the body is not decoded. Returns joined
+// errors for the JSON decode failure and HTTP status code (*Error) like
+// DecodeResponse.
+//
+// Buffers response body in memory.
+func DecodeResponseByStatus(resp *http.Response, outs map[int]any, def any) error {
+	_, b, err := readJSONBody(resp)
+	if err != nil {
+		return err
+	}
+	out, ok := outs[resp.StatusCode]
+	if !ok {
+		out = def
+	}
+	var errs []error
+	if out != nil {
+		if err = decodeOutput(b, out); err != nil {
+			errs = append(errs, fmt.Errorf("failed to decode server response for status %d: %w", resp.StatusCode, err))
+		}
+	}
+	if len(errs) != 0 || resp.StatusCode >= 400 {
+		herr := newError(resp, b, len(errs) != 0)
+		if len(errs) == 0 {
+			herr.Decoded = unwrapOutput(out)
+		}
+		errs = append(errs, herr)
+	}
+	return errors.Join(errs...)
+}
+
+// unwrapOutput returns the output passed to Lenient().
+func unwrapOutput(out any) any {
+	if l, ok := out.(lenientOutput); ok {
+		return l.out
+	}
+	return out
+}
+
+// decodeOutput decodes b into out, honoring Lenient().
+func decodeOutput(b []byte, out any) error {
+	if l, ok := out.(lenientOutput); ok {
+		return decodeJSON(b, l.out, true)
+	}
+	return decodeJSON(b, out, false)
+}
+
+// readJSONBody reads the whole response body and closes it. It returns both
+// the raw body and the body converted to UTF-8 according to the charset in the
+// Content-Type, without leading byte order mark.
+func readJSONBody(resp *http.Response) ([]byte, []byte, error) {
+	raw, err := readBody(resp)
+	if err != nil {
+		return raw, nil, err
+	}
+	b, err := toUTF8(resp.Header.Get("Content-Type"), raw)
+	if err != nil {
+		return raw, nil, errors.Join(err, newError(resp, raw, true))
+	}
+	return raw, b, nil
+}
+
+// readBody reads the whole response body and closes it.
+func readBody(resp *http.Response) ([]byte, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if resp.ContentLength > 0 && resp.ContentLength <= maxPooledBuffer {
+		buf.Grow(int(resp.ContentLength) + bytes.MinRead)
+	}
+	_, err := buf.ReadFrom(resp.Body)
+	b := bytes.Clone(buf.Bytes())
 	if err2 := resp.Body.Close(); err == nil {
 		err = err2
 	}
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = &TruncatedResponseError{Received: int64(len(b)), Expected: resp.ContentLength, Err: err}
+	}
 	if err != nil {
-		return fmt.Errorf("failed to read server response: %w", err)
+		return b, fmt.Errorf("failed to read server response: %w", err)
 	}
-	if err = decodeJSON(b, out, c.Lenient); err != nil {
-		return errors.Join(err, &Error{ResponseBody: b, StatusCode: resp.StatusCode, Status: resp.Status, PrintBody: true})
+	return b, nil
+}
+
+// TruncatedResponseError is returned when the connection is closed before
+// the whole response body is received.
+type TruncatedResponseError struct {
+	Received int64
+	// Expected is the Content-Length, or -1 if it was unknown.
+	Expected int64
+	Err      error
+}
+
+func (t *TruncatedResponseError) Error() string {
+	if t.Expected < 0 {
+		return fmt.Sprintf("truncated response after %d bytes: %v", t.Received, t.Err)
 	}
-	return nil
+	return fmt.Sprintf("truncated response: received %d of %d bytes: %v", t.Received, t.Expected, t.Err)
+}
+
+func (t *TruncatedResponseError) Unwrap() error {
+	return t.Err
+}
+
+// Lenient marks an output passed to DecodeResponse as accepting unknown
+// fields, while the other candidates are still decoded strictly.
+//
+// This is useful for fallback error shapes that often carry extra vendor
+// specific fields.
+func Lenient(out any) any {
+	return lenientOutput{out: out}
+}
+
+type lenientKey struct{}
+
+// OverrideLenient returns a context overriding Client.Lenient for the calls
+// using it.
+//
+// For example, a code base can decode strictly in tests while being lenient
+// in production for the same call sites.
+func OverrideLenient(ctx context.Context, lenient bool) context.Context {
+	return context.WithValue(ctx, lenientKey{}, lenient)
+}
+
+type lenientOutput struct {
+	out any
+}
+
+func (c *Client) decodeResponse(resp *http.Response, out any) error {
+	if c.canStreamDecode(resp, out) {
+		return c.streamDecode(resp, out)
+	}
+	_, b, err := readJSONBody(resp)
+	if err != nil {
+		return err
+	}
+	return c.decodeBody(resp, b, out)
+}
+
+// decodeBody decodes the already read response body b into out.
+func (c *Client) decodeBody(resp *http.Response, b []byte, out any) error {
+	ctx := context.Background()
+	if resp.Request != nil {
+		ctx = resp.Request.Context()
+	}
+	var err error
+	if len(bytes.TrimSpace(b)) == 0 {
+		if !c.DisallowEmptyBody && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return nil
+		}
+		err = ErrEmptyBody
+	} else if c.RequireJSON && !isJSONContentType(resp.Header.Get("Content-Type")) {
+		err = &ContentTypeError{ContentType: resp.Header.Get("Content-Type")}
+	} else {
+		err = c.decode(ctx, b, out)
+	}
+	if err != nil {
+		c.shared().stats.decodeFailure()
+		herr := newError(resp, b, true)
+		if serr := (*json.SyntaxError)(nil); errors.As(err, &serr) {
+			if isHTML(b) {
+				// The page's markup is noise; the body is still in ResponseBody.
+				err = &HTMLPageError{StatusCode: resp.StatusCode, Title: htmlTitle(b)}
+				herr.PrintBody = false
+			} else if api := herr.API(); api != nil {
+				// A recognized non-JSON error document, e.g. XML. Its message is
+				// more useful than the JSON syntax error.
+				err = api
+			}
+		}
+		err = errors.Join(err, herr)
+	}
+	if c.DumpDir != "" && (err != nil || resp.StatusCode >= 400) {
+		_ = dumpExchange(c.DumpDir, resp, b)
+	}
+	return err
+}
+
+// decode runs the optional checks enabled on the client then decodes b into
+// out.
+func (c *Client) decode(ctx context.Context, b []byte, out any) error {
+	orig := b
+	if c.Relaxed {
+		b = relaxJSON(b)
+	}
+	if c.SnakeCase {
+		if t := reflect.TypeOf(out); t != nil {
+			if b2, err := renameKeysJSON(b, t, true); err == nil {
+				b = b2
+			}
+		}
+	}
+	if c.DisallowDuplicateKeys {
+		if err := checkDuplicateKeys(b, reflect.TypeOf(out)); err != nil {
+			return err
+		}
+	}
+	if c.CaseSensitive {
+		if err := checkFieldCase(b, out); err != nil {
+			return err
+		}
+	}
+	if c.StrictNumbers {
+		if err := checkNumbers(b, out); err != nil {
+			return err
+		}
+	}
+	if c.DisallowNull {
+		if err := checkNulls(b, out); err != nil {
+			return err
+		}
+	}
+	lenient := c.Lenient
+	if v, ok := ctx.Value(lenientKey{}).(bool); ok {
+		lenient = v
+	}
+	var err error
+	if c.Unmarshal != nil {
+		err = decodeWith(c.Unmarshal, b, out, lenient)
+	} else {
+		err = decodeJSON(b, out, lenient)
+	}
+	if err != nil && !bytes.Equal(orig, b) {
+		// The offsets would point into the rewritten body.
+		clearOffsets(err)
+	}
+	return err
 }
 
 func decodeJSON(b []byte, out any, lenient bool) error {
+	orig := b
+	if t := reflect.TypeOf(out); t != nil && hasFormatTags(t) {
+		var err error
+		if b, err = applyFormatTags(b, t); err != nil {
+			return err
+		}
+	}
 	d := json.NewDecoder(bytes.NewReader(b))
 	if !lenient {
 		d.DisallowUnknownFields()
@@ -198,8 +1228,9 @@ func decodeJSON(b []byte, out any, lenient bool) error {
 			d = json.NewDecoder(bytes.NewReader(b))
 			d.UseNumber()
 			if d.Decode(&m) == nil {
-				if err2 := errors.Join(FindExtraKeys(reflect.TypeOf(out), m)...); err2 != nil {
-					return err2
+				if errs := FindExtraKeys(reflect.TypeOf(out), m); len(errs) != 0 {
+					setOffsets(orig, b, errs)
+					return errors.Join(errs...)
 				}
 			}
 			return err
@@ -213,16 +1244,20 @@ func decodeJSON(b []byte, out any, lenient bool) error {
 //
 // For best result, value should be either map[string]any or []any.
 func FindExtraKeys(t reflect.Type, value any) []error {
-	return findExtraKeysGeneric(t, t, value, "")
+	return findExtraKeysGeneric(t, t, value, "", "$")
 }
 
-func findExtraKeysGeneric(root, t reflect.Type, value any, prefix string) []error {
+func findExtraKeysGeneric(root, t reflect.Type, value any, prefix, path string) []error {
 	if value == nil {
 		return nil
 	}
 	for t.Kind() == reflect.Pointer {
 		t = t.Elem()
 	}
+	if isLeafType(t) {
+		// The type decodes itself, its JSON representation is opaque.
+		return nil
+	}
 	for {
 		if v := reflect.ValueOf(value); v.Kind() == reflect.Pointer {
 			value = v.Elem().Interface()
@@ -233,18 +1268,19 @@ func findExtraKeysGeneric(root, t reflect.Type, value any, prefix string) []erro
 	switch t.Kind() {
 	case reflect.Struct:
 		if v, ok := value.(map[string]any); ok {
-			return findExtraKeysStruct(root, t, v, prefix)
+			return findExtraKeysStruct(root, t, v, prefix, path)
 		}
 		return []error{&UnknownFieldError{
 			StructType: root.String(),
 			Field:      prefix,
+			Path:       path,
 			FieldType:  fmt.Sprintf("%T", value),
 			FieldValue: value,
 		}}
 	case reflect.Map:
-		return findExtraKeysMap(root, t, value, prefix)
+		return findExtraKeysMap(root, t, value, prefix, path)
 	case reflect.Slice, reflect.Array:
-		return findExtraKeysSlice(root, t, value, prefix)
+		return findExtraKeysSlice(root, t, value, prefix, path)
 	case reflect.Bool,
 		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
 		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
@@ -253,18 +1289,24 @@ func findExtraKeysGeneric(root, t reflect.Type, value any, prefix string) []erro
 		reflect.String:
 		// TODO: Confirm the type.
 		return nil
-	// case reflect.Chan, reflect.Func, reflect.Interface, reflect.UnsafePointer:
+	case reflect.Interface:
+		// any accepts arbitrary content, so nothing beneath it is unknown. This
+		// covers map[string]any and []any too. encoding/json refuses to decode
+		// into a non-empty interface; that error is returned as is.
+		return nil
+	// case reflect.Chan, reflect.Func, reflect.UnsafePointer:
 	default:
 		return []error{&UnknownFieldError{
 			StructType: root.String(),
 			Field:      prefix,
+			Path:       path,
 			FieldType:  fmt.Sprintf("%T", value),
 			FieldValue: value,
 		}}
 	}
 }
 
-func findExtraKeysStruct(root, t reflect.Type, data map[string]any, prefix string) []error {
+func findExtraKeysStruct(root, t reflect.Type, data map[string]any, prefix, path string) []error {
 	validFields := collectJSONFields(t)
 	var out []error
 	for key, value := range data {
@@ -272,26 +1314,37 @@ func findExtraKeysStruct(root, t reflect.Type, data map[string]any, prefix strin
 		if prefix != "" {
 			v = prefix + "." + key
 		}
+		p := jsonPathKey(path, key)
 		if name, ok := validFields[key]; !ok {
 			out = append(out, &UnknownFieldError{
 				StructType: root.String(),
 				Field:      v,
+				Path:       p,
 				FieldType:  fmt.Sprintf("%T", value),
 				FieldValue: value,
 			})
 		} else if st, ok := t.FieldByName(name); ok {
-			out = append(out, findExtraKeysGeneric(root, st.Type, value, v)...)
+			out = append(out, findExtraKeysGeneric(root, st.Type, value, v, p)...)
 		}
 	}
 	return out
 }
 
+// jsonFieldsCache maps a struct reflect.Type to its collectJSONFields result.
+var jsonFieldsCache sync.Map
+
 // collectJSONFields returns a map from JSON field name to Go field name for a struct type,
 // recursing into anonymous (embedded) fields. Fields with json:"-" tags are skipped.
+//
+// The result is cached per type and shared; it must not be modified.
 func collectJSONFields(t reflect.Type) map[string]string {
+	if f, ok := jsonFieldsCache.Load(t); ok {
+		return f.(map[string]string)
+	}
 	fields := make(map[string]string, t.NumField())
 	collectJSONFieldsRecursive(t, fields)
-	return fields
+	f, _ := jsonFieldsCache.LoadOrStore(t, fields)
+	return f.(map[string]string)
 }
 
 func collectJSONFieldsRecursive(t reflect.Type, fields map[string]string) {
@@ -321,12 +1374,13 @@ func collectJSONFieldsRecursive(t reflect.Type, fields map[string]string) {
 	}
 }
 
-func findExtraKeysMap(root, t reflect.Type, data any, prefix string) []error {
+func findExtraKeysMap(root, t reflect.Type, data any, prefix, path string) []error {
 	d2 := reflect.ValueOf(data)
 	if d2.Kind() != reflect.Map {
 		return []error{&UnknownFieldError{
 			StructType: root.String(),
 			Field:      prefix,
+			Path:       path,
 			FieldType:  fmt.Sprintf("%T", data),
 			FieldValue: data,
 		}}
@@ -339,13 +1393,13 @@ func findExtraKeysMap(root, t reflect.Type, data any, prefix string) []error {
 			// happen.
 			out = append(out, fmt.Errorf("invalid json: %s[%q] is not a valid JSON key; type %s, must be string", prefix, key.String(), key.Type()))
 		}
-		v := d2.MapIndex(key)
-		out = append(out, findExtraKeysGeneric(root, vt, v, prefix+fmt.Sprintf("[%s]", key))...)
+		v := d2.MapIndex(key).Interface()
+		out = append(out, findExtraKeysGeneric(root, vt, v, prefix+fmt.Sprintf("[%s]", key), jsonPathKey(path, key.String()))...)
 	}
 	return out
 }
 
-func findExtraKeysSlice(root, t reflect.Type, data any, prefix string) []error {
+func findExtraKeysSlice(root, t reflect.Type, data any, prefix, path string) []error {
 	d2 := reflect.ValueOf(data)
 	if d2.Kind() != reflect.Slice && d2.Kind() != reflect.Array {
 		// []byte fields are decoded by json.Unmarshal into map[string]any as
@@ -358,6 +1412,7 @@ func findExtraKeysSlice(root, t reflect.Type, data any, prefix string) []error {
 			&UnknownFieldError{
 				StructType: root.String(),
 				Field:      prefix,
+				Path:       path,
 				FieldType:  fmt.Sprintf("%T", data),
 				FieldValue: data,
 			},
@@ -365,11 +1420,25 @@ func findExtraKeysSlice(root, t reflect.Type, data any, prefix string) []error {
 	}
 	var out []error
 	for i := range d2.Len() {
-		out = append(out, findExtraKeysGeneric(root, t.Elem(), d2.Index(i).Interface(), prefix+fmt.Sprintf("[%d]", i))...)
+		out = append(out, findExtraKeysGeneric(root, t.Elem(), d2.Index(i).Interface(), prefix+fmt.Sprintf("[%d]", i), path+"["+strconv.Itoa(i)+"]")...)
 	}
 	return out
 }
 
+var (
+	jsonUnmarshalerType = reflect.TypeFor[json.Unmarshaler]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// isLeafType reports whether t implements json.Unmarshaler or
+// encoding.TextUnmarshaler, like time.Time. These types control their own
+// decoding so their content must not be checked for unknown fields.
+func isLeafType(t reflect.Type) bool {
+	p := reflect.PointerTo(t)
+	return t.Implements(jsonUnmarshalerType) || p.Implements(jsonUnmarshalerType) ||
+		t.Implements(textUnmarshalerType) || p.Implements(textUnmarshalerType)
+}
+
 // isByteSliceOrArray reports whether t is []byte or [N]byte.
 func isByteSliceOrArray(t reflect.Type) bool {
 	return (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && t.Elem().Kind() == reflect.Uint8
@@ -377,6 +1446,103 @@ func isByteSliceOrArray(t reflect.Type) bool {
 
 //
 
+// ErrEmptyBody is returned when a JSON response body was expected but it is
+// empty. See Client.DisallowEmptyBody.
+var ErrEmptyBody = errors.New("empty response body")
+
+// ContentTypeError is returned when Client.RequireJSON is set and the
+// response is not JSON.
+type ContentTypeError struct {
+	ContentType string
+}
+
+func (c *ContentTypeError) Error() string {
+	return fmt.Sprintf("unexpected response Content-Type %q, expected JSON", c.ContentType)
+}
+
+// isJSONContentType reports whether the media type is application/json or
+// has a +json suffix, like application/problem+json.
+func isJSONContentType(contentType string) bool {
+	t, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (t == "application/json" || strings.HasSuffix(t, "+json"))
+}
+
+// HTMLPageError is returned when a response that should be JSON is an HTML
+// page, typically an error page from a proxy or a load balancer.
+type HTMLPageError struct {
+	StatusCode int
+	// Title is the content of the page's <title> element, if any.
+	Title string
+}
+
+func (h *HTMLPageError) Error() string {
+	return fmt.Sprintf("server returned an HTML page, status %d, title: %q", h.StatusCode, h.Title)
+}
+
+// isHTML reports whether b starts like an HTML document.
+func isHTML(b []byte) bool {
+	b = bytes.TrimLeft(b, " \t\r\n\uFEFF")
+	for _, p := range []string{"<!doctype html", "<html"} {
+		if len(b) >= len(p) && strings.EqualFold(string(b[:len(p)]), p) {
+			return true
+		}
+	}
+	return false
+}
+
+// htmlTitle returns the content of the <title> element.
+func htmlTitle(b []byte) string {
+	// Lower case ASCII only so the offsets match b.
+	l := bytes.Clone(b)
+	for i, c := range l {
+		if 'A' <= c && c <= 'Z' {
+			l[i] = c + 'a' - 'A'
+		}
+	}
+	s := string(l)
+	i := strings.Index(s, "<title")
+	if i == -1 {
+		return ""
+	}
+	j := strings.IndexByte(s[i:], '>')
+	if j == -1 {
+		return ""
+	}
+	start := i + j + 1
+	end := strings.Index(s[start:], "</title")
+	if end == -1 {
+		return ""
+	}
+	// Use the original bytes to keep the case.
+	return strings.Join(strings.Fields(html.UnescapeString(string(b[start:start+end]))), " ")
+}
+
+// InsecureURLError is returned when Client.RequireHTTPS refuses a plain
+// http:// URL.
+type InsecureURLError struct {
+	// URL is the refused URL, with secrets redacted.
+	URL string
+}
+
+func (i *InsecureURLError) Error() string {
+	return fmt.Sprintf("refusing to send a request over plain http to %s", i.URL)
+}
+
+// ResponseTooLargeError is returned when a response body is larger than
+// Client.MaxResponseSize.
+type ResponseTooLargeError struct {
+	// Size is the announced Content-Length, or -1 if it was not known.
+	Size  int64
+	Limit int64
+}
+
+func (r *ResponseTooLargeError) Error() string {
+	if r.Size < 0 {
+		return fmt.Sprintf("response body is larger than %d bytes", r.Limit)
+	}
+	return fmt.Sprintf("response body of %d bytes is larger than %d bytes", r.Size, r.Limit)
+}
+
 // Error represents an HTTP request that returned an HTTP error.
 // It contains the response body if any.
 type Error struct {
@@ -384,29 +1550,255 @@ type Error struct {
 	StatusCode   int
 	Status       string
 	PrintBody    bool
+	// Location is the Location header of a redirect that was not followed.
+	Location string
+	// Method and URL identify the request, with secrets in the URL redacted.
+	Method string
+	URL    string
+	// RequestID is the value of the first of RequestIDHeaders found in the
+	// response, to quote when contacting the API vendor.
+	RequestID string
+	// Decoded is the output the error response body was decoded into by
+	// DecodeResponse, DecodeResponseBody or DecodeResponseByStatus. If it
+	// implements error, the Error unwraps to it so errors.As() finds it.
+	Decoded any
 }
 
-// Error implements error, returning "http <status code>".
+// Unwrap returns Decoded if it implements error.
+func (h *Error) Unwrap() error {
+	err, _ := h.Decoded.(error)
+	return err
+}
+
+// RequestIDHeaders are the response headers holding a server side request
+// or correlation ID, saved in Error.RequestID.
+var RequestIDHeaders = []string{
+	"X-Request-Id",
+	"X-Amzn-Requestid",
+	"X-Amz-Request-Id",
+	"X-Github-Request-Id",
+	"Request-Id",
+	"X-Correlation-Id",
+	"Cf-Ray",
+}
+
+// newError returns an *Error for resp and its already read body b.
+func newError(resp *http.Response, b []byte, printBody bool) *Error {
+	e := &Error{ResponseBody: b, StatusCode: resp.StatusCode, Status: resp.Status, PrintBody: printBody}
+	if req := resp.Request; req != nil {
+		e.Method = req.Method
+		e.URL = redactURL(req.URL)
+	}
+	for _, h := range RequestIDHeaders {
+		if v := resp.Header.Get(h); v != "" {
+			e.RequestID = v
+			break
+		}
+	}
+	return e
+}
+
+// ErrorBodyLimit is the maximum number of bytes of the response body printed
+// by Error.Error(). The full body is always available in Error.ResponseBody.
+//
+// Set to 0 to print the whole body.
+var ErrorBodyLimit = 4096
+
+// Error implements error, returning "<method> <url>: http <status code>".
+//
+// When PrintBody is set, the response body is appended, truncated to
+// ErrorBodyLimit bytes with non-printable characters escaped.
 func (h *Error) Error() string {
 	out := fmt.Sprintf("http %d", h.StatusCode)
+	if h.Method != "" {
+		out = h.Method + " " + h.URL + ": " + out
+	}
+	if h.Location != "" {
+		out += " redirect to " + h.Location
+	}
+	if h.RequestID != "" {
+		out += " (request id " + h.RequestID + ")"
+	}
 	if h.PrintBody {
-		out += "\n" + string(h.ResponseBody)
+		out += "\n" + printableBody(h.ResponseBody, ErrorBodyLimit)
 	}
 	return out
 }
 
+// printableBody returns b as a string safe to print, escaping invalid UTF-8
+// and non-printable characters and truncating it to limit bytes if limit is
+// greater than 0.
+func printableBody(b []byte, limit int) string {
+	extra := 0
+	if limit > 0 && len(b) > limit {
+		// Do not cut a rune in half.
+		for limit > 0 && !utf8.RuneStart(b[limit]) {
+			limit--
+		}
+		extra = len(b) - limit
+		b = b[:limit]
+	}
+	var sb strings.Builder
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		switch {
+		case r == utf8.RuneError && size == 1:
+			fmt.Fprintf(&sb, "\\x%02x", b[0])
+		case r == '\n' || r == '\r' || r == '\t' || unicode.IsPrint(r):
+			sb.WriteRune(r)
+		default:
+			q := strconv.QuoteRune(r)
+			sb.WriteString(q[1 : len(q)-1])
+		}
+		b = b[size:]
+	}
+	if extra > 0 {
+		fmt.Fprintf(&sb, "… (%d more bytes)", extra)
+	}
+	return sb.String()
+}
+
+// Retryable reports whether the request may succeed if retried, based on the
+// status code: 429, 502, 503 and 504.
+func (h *Error) Retryable() bool {
+	switch h.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// StatusClass returns the status code class, e.g. 4 for a 404.
+func (h *Error) StatusClass() int {
+	return h.StatusCode / 100
+}
+
+// IsClientError reports whether the status code is 4xx.
+func (h *Error) IsClientError() bool {
+	return h.StatusClass() == 4
+}
+
+// IsServerError reports whether the status code is 5xx.
+func (h *Error) IsServerError() bool {
+	return h.StatusClass() == 5
+}
+
+// Sentinels matching an *Error of the corresponding status class with
+// errors.Is().
+var (
+	ErrStatusClass3xx = errors.New("http 3xx")
+	ErrStatusClass4xx = errors.New("http 4xx")
+	ErrStatusClass5xx = errors.New("http 5xx")
+)
+
+// Is implements errors.Is() support for ErrStatusClass3xx,
+// ErrStatusClass4xx and ErrStatusClass5xx.
+func (h *Error) Is(target error) bool {
+	switch target {
+	case ErrStatusClass3xx:
+		return h.StatusClass() == 3
+	case ErrStatusClass4xx:
+		return h.StatusClass() == 4
+	case ErrStatusClass5xx:
+		return h.StatusClass() == 5
+	default:
+		return false
+	}
+}
+
+// IsRetryable reports whether err is worth retrying.
+//
+// It returns true for a wrapped *Error whose Retryable() returns true and for
+// transient network failures as classified by retry.IsTransient, like a
+// timeout or a connection reset or refused. Context cancellation and
+// permanent failures like a TLS verification error are never retryable.
+func IsRetryable(err error) bool {
+	var herr *Error
+	if errors.As(err, &herr) {
+		return herr.Retryable()
+	}
+	return retry.IsTransient(err)
+}
+
+// IsStatus reports whether err wraps an *Error with the status code code.
+func IsStatus(err error, code int) bool {
+	var herr *Error
+	return errors.As(err, &herr) && herr.StatusCode == code
+}
+
+// IsNotFound reports whether err wraps an *Error with status 404.
+func IsNotFound(err error) bool {
+	return IsStatus(err, http.StatusNotFound)
+}
+
+// IsUnauthorized reports whether err wraps an *Error with status 401.
+func IsUnauthorized(err error) bool {
+	return IsStatus(err, http.StatusUnauthorized)
+}
+
+// IsTooManyRequests reports whether err wraps an *Error with status 429.
+func IsTooManyRequests(err error) bool {
+	return IsStatus(err, http.StatusTooManyRequests)
+}
+
 // UnknownFieldError is one unknown field in the JSON response.
 type UnknownFieldError struct {
 	StructType string
 	Field      string
 	FieldType  string
 	FieldValue any
+	// Path is the JSONPath of the field, like "$.items[3].metadata.newField".
+	Path string
+	// Offset is the byte offset of the field in the response body, after the
+	// byte order mark is stripped and the body is converted to UTF-8. It is
+	// only set when decoding a body, not by FindExtraKeys. It is -1 when the
+	// body was rewritten before decoding, by Client.Relaxed, Client.SnakeCase
+	// or format tags, since offsets in the rewritten body would be misleading.
+	Offset int64
 }
 
+// UnknownFieldValueLimit is the maximum number of bytes of a string value
+// printed by UnknownFieldError.Error(). Objects and arrays are summarized
+// instead of printed. The full value is always available in
+// UnknownFieldError.FieldValue.
+//
+// Set to 0 to print the whole value.
+var UnknownFieldValueLimit = 256
+
 // Error implements the error interface.
 func (e *UnknownFieldError) Error() string {
-	if e.FieldValue == nil {
-		return fmt.Sprintf("unknown field %s.%s of type %s with value %v", e.StructType, e.Field, e.FieldType, e.FieldValue)
+	return fmt.Sprintf("unknown field %s.%s of type %s with value %s", e.StructType, e.Field, e.FieldType, summarizeValue(e.FieldValue, UnknownFieldValueLimit))
+}
+
+// summarizeValue returns v quoted and truncated to limit bytes, or a summary
+// like "object with 14 keys" for containers.
+func summarizeValue(v any, limit int) string {
+	if v == nil {
+		return "<nil>"
+	}
+	switch r := reflect.ValueOf(v); r.Kind() {
+	case reflect.Map:
+		return plural(r.Len(), "object with %d key")
+	case reflect.Slice, reflect.Array:
+		return plural(r.Len(), "array with %d item")
+	case reflect.String:
+		s := r.String()
+		if limit <= 0 || len(s) <= limit {
+			return strconv.Quote(s)
+		}
+		for limit > 0 && !utf8.RuneStart(s[limit]) {
+			limit--
+		}
+		return fmt.Sprintf("%q… (%d more bytes)", s[:limit], len(s)-limit)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func plural(n int, format string) string {
+	if n != 1 {
+		format += "s"
 	}
-	return fmt.Sprintf("unknown field %s.%s of type %s with value %q", e.StructType, e.Field, e.FieldType, e.FieldValue)
+	return fmt.Sprintf(format, n)
 }
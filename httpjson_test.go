@@ -5,13 +5,29 @@
 package httpjson
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
 	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
+	"time"
+
+	"github.com/maruel/httpjson/retry"
 )
 
 func TestClient_Get(t *testing.T) {
@@ -72,103 +88,1042 @@ func TestClient_Get_header(t *testing.T) {
 	})
 }
 
+func TestClient_Get_filter_headers(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var got []string
+		for k := range r.Header {
+			if strings.HasPrefix(k, "X-") {
+				got = append(got, k)
+			}
+		}
+		slices.Sort(got)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(got)
+	}))
+	defer ts.Close()
+	hdr := http.Header{"X-Public": {"1"}, "X-Internal-Auth": {"secret"}, "X-Other": {"2"}}
+	data := []struct {
+		name string
+		c    *Client
+		want []string
+	}{
+		{"none", &Client{}, []string{"X-Internal-Auth", "X-Other", "X-Public"}},
+		{"deny", &Client{DenyHeaders: []string{"x-internal-auth"}}, []string{"X-Other", "X-Public"}},
+		{"allow", &Client{AllowHeaders: []string{"x-public", "Content-Type"}}, []string{"X-Public"}},
+	}
+	for _, line := range data {
+		t.Run(line.name, func(t *testing.T) {
+			var got []string
+			if err := line.c.Get(context.Background(), ts.URL, hdr, &got); err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, line.want) {
+				t.Errorf("Unexpected\nwant: %v\ngot:  %v", line.want, got)
+			}
+		})
+	}
+}
+
+func TestClient_Get_accept(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/html" {
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte(`<html></html>`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/problem+json")
+		_ = json.NewEncoder(w).Encode(r.Header.Get("Accept"))
+	}))
+	defer ts.Close()
+	var got string
+	c := Client{}
+	if err := c.Get(context.Background(), ts.URL, nil, &got); err != nil {
+		t.Fatal(err)
+	}
+	if want := "application/json"; got != want {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", want, got)
+	}
+	c = Client{Accept: "application/vnd.github+json", RequireJSON: true}
+	if err := c.Get(context.Background(), ts.URL, nil, &got); err != nil {
+		t.Fatal(err)
+	}
+	if want := "application/vnd.github+json"; got != want {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", want, got)
+	}
+	// The Accept header set by the caller is kept.
+	req, err := http.NewRequestWithContext(context.Background(), "GET", ts.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept", "text/csv")
+	resp, err := c.Do(req, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.decodeResponse(resp, &got); err != nil {
+		t.Fatal(err)
+	}
+	if want := "text/csv"; got != want {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", want, got)
+	}
+	err = c.Get(context.Background(), ts.URL+"/html", nil, &got)
+	var cerr *ContentTypeError
+	if !errors.As(err, &cerr) {
+		t.Fatalf("expected ContentTypeError, got %v", err)
+	}
+	if cerr.ContentType != "text/html" {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", "text/html", cerr.ContentType)
+	}
+}
+
+func TestClient_content_type(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(r.Header.Get("Content-Type"))
+	}))
+	defer ts.Close()
+	var got string
+	c := Client{}
+	if err := c.Get(context.Background(), ts.URL, nil, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != "" {
+		t.Errorf("Unexpected\nwant: %q\ngot:  %q", "", got)
+	}
+	if err := c.Post(context.Background(), ts.URL, nil, 1, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != jsonContentType {
+		t.Errorf("Unexpected\nwant: %q\ngot:  %q", jsonContentType, got)
+	}
+	c.AlwaysSendContentType = true
+	if err := c.Get(context.Background(), ts.URL, nil, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != jsonContentType {
+		t.Errorf("Unexpected\nwant: %q\ngot:  %q", jsonContentType, got)
+	}
+
+	// Custom per client then per call.
+	c = Client{ContentType: "application/merge-patch+json"}
+	if err := c.Post(context.Background(), ts.URL, nil, 1, &got); err != nil {
+		t.Fatal(err)
+	}
+	if want := "application/merge-patch+json"; got != want {
+		t.Errorf("Unexpected\nwant: %q\ngot:  %q", want, got)
+	}
+	hdr := http.Header{"Content-Type": {"application/json-patch+json"}}
+	if err := c.Post(context.Background(), ts.URL, hdr, []int{}, &got); err != nil {
+		t.Fatal(err)
+	}
+	if want := "application/json-patch+json"; got != want {
+		t.Errorf("Unexpected\nwant: %q\ngot:  %q", want, got)
+	}
+}
+
+func TestClient_Get_hooks(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h := r.Header.Get("X-Test"); h != "hooked" {
+			t.Errorf("Unexpected\nwant: %v\ngot:  %v", "hooked", h)
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("X-Reply", "pong")
+		w.Write([]byte("null"))
+	}))
+	defer ts.Close()
+
+	var got []string
+	c := Client{
+		OnRequest: []func(*http.Request) error{
+			func(r *http.Request) error {
+				r.Header.Set("X-Test", "hooked")
+				got = append(got, "request")
+				return nil
+			},
+		},
+		OnResponse: []func(*http.Response) error{
+			func(r *http.Response) error {
+				got = append(got, "response "+r.Header.Get("X-Reply"))
+				return nil
+			},
+		},
+	}
+	if err := c.Get(context.Background(), ts.URL, nil, &map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"request", "response pong"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", want, got)
+	}
+
+	errHook := errors.New("hook")
+	c = Client{OnRequest: []func(*http.Request) error{func(*http.Request) error { return errHook }}}
+	if err := c.Get(context.Background(), ts.URL, nil, &map[string]string{}); !errors.Is(err, errHook) {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", errHook, err)
+	}
+	c = Client{OnResponse: []func(*http.Response) error{func(*http.Response) error { return errHook }}}
+	hdr := http.Header{"X-Test": []string{"hooked"}}
+	if err := c.Get(context.Background(), ts.URL, hdr, &map[string]string{}); !errors.Is(err, errHook) {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", errHook, err)
+	}
+}
+
+func TestClient_Get_max_concurrent(t *testing.T) {
+	t.Parallel()
+	var inflight, peak atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inflight.Add(1)
+		defer inflight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write([]byte("null"))
+	}))
+	defer ts.Close()
+
+	c := Client{MaxConcurrent: 2}
+	var wg sync.WaitGroup
+	for range 6 {
+		wg.Go(func() {
+			if err := c.Get(context.Background(), ts.URL, nil, &map[string]string{}); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	wg.Wait()
+	if p := peak.Load(); p > 2 {
+		t.Errorf("Unexpected peak concurrency %d", p)
+	}
+
+	// A canceled context doesn't wait for a slot.
+	c = Client{MaxConcurrent: 1}
+	resp, err := c.GetRequest(context.Background(), ts.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = c.GetRequest(ctx, ts.URL, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", context.Canceled, err)
+	}
+	_ = resp.Body.Close()
+	if err := c.Get(context.Background(), ts.URL, nil, &map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewHTTP2Client(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(map[string]int{"proto": r.ProtoMajor})
+	}))
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	defer ts.Close()
+
+	c := NewHTTP2Client()
+	c.Client.Transport.(*http.Transport).TLSClientConfig = ts.Client().Transport.(*http.Transport).TLSClientConfig
+	var out struct {
+		Proto int `json:"proto"`
+	}
+	if err := c.Get(context.Background(), ts.URL, nil, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Proto != 2 {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", 2, out.Proto)
+	}
+}
+
+func TestNewTransport(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(map[string]int{"proto": r.ProtoMajor})
+	}))
+	defer ts.Close()
+	// Unlike NewHTTP2Client, plain http and HTTP/1.1 work.
+	c := Client{Client: &http.Client{Transport: NewTransport()}}
+	var out struct {
+		Proto int `json:"proto"`
+	}
+	if err := c.Get(context.Background(), ts.URL, nil, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Proto != 1 {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", 1, out.Proto)
+	}
+}
+
+func TestClient_Get_disallow_redirects(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api" {
+			http.Redirect(w, r, "/login", http.StatusFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+	if err := (&Client{}).Get(context.Background(), ts.URL+"/api", nil, &map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	c := Client{DisallowRedirects: true}
+	err := c.Get(context.Background(), ts.URL+"/api", nil, &map[string]string{})
+	var herr *Error
+	if !errors.As(err, &herr) {
+		t.Fatalf("expected Error, got %v", err)
+	}
+	if herr.StatusCode != http.StatusFound || herr.Location != "/login" {
+		t.Errorf("Unexpected %d %q", herr.StatusCode, herr.Location)
+	}
+	if want := "GET " + ts.URL + "/api: http 302 redirect to /login"; err.Error() != want {
+		t.Errorf("Unexpected\nwant: %q\ngot:  %q", want, err.Error())
+	}
+}
+
+func TestClient_Get_require_https(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+	tls := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, ts.URL, http.StatusFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer tls.Close()
+
+	// Loopback is allowed by default.
+	c := Client{RequireHTTPS: true}
+	if err := c.Get(context.Background(), ts.URL, nil, &map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	c = Client{Client: tls.Client(), RequireHTTPS: true, InsecureHosts: []string{}}
+	if err := c.Get(context.Background(), tls.URL, nil, &map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	err := c.Get(context.Background(), ts.URL+"/?token=secret", nil, &map[string]string{})
+	var ierr *InsecureURLError
+	if !errors.As(err, &ierr) {
+		t.Fatalf("expected InsecureURLError, got %v", err)
+	}
+	if want := ts.URL + "/?token=REDACTED"; ierr.URL != want {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", want, ierr.URL)
+	}
+	// Redirects are checked too.
+	err = c.Get(context.Background(), tls.URL+"/redirect", nil, &map[string]string{})
+	if !errors.As(err, &ierr) {
+		t.Fatalf("expected InsecureURLError, got %v", err)
+	}
+}
+
+func TestClient_Get_max_response_size(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		body := `{"output":"` + strings.Repeat("a", 100) + `"}`
+		if r.URL.Path == "/stream" {
+			// Unknown length.
+			_, _ = w.Write([]byte(body[:10]))
+			w.(http.Flusher).Flush()
+			_, _ = w.Write([]byte(body[10:]))
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	defer ts.Close()
+	var out struct {
+		Output string `json:"output"`
+	}
+	c := Client{MaxResponseSize: 200}
+	if err := c.Get(context.Background(), ts.URL+"/stream", nil, &out); err != nil {
+		t.Fatal(err)
+	}
+	c = Client{MaxResponseSize: 50}
+	data := []struct {
+		path string
+		size int64
+	}{
+		{"/", 113},
+		{"/stream", -1},
+	}
+	for _, line := range data {
+		err := c.Get(context.Background(), ts.URL+line.path, nil, &out)
+		var rerr *ResponseTooLargeError
+		if !errors.As(err, &rerr) {
+			t.Fatalf("%s: expected ResponseTooLargeError, got %v", line.path, err)
+		}
+		if rerr.Size != line.size || rerr.Limit != 50 {
+			t.Errorf("%s: Unexpected %+v", line.path, rerr)
+		}
+	}
+}
+
+func TestClient_GetBytes(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		if r.URL.Path == "/fail" {
+			w.Header().Set("CF-Ray", "abc-SJC")
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("bad"))
+			return
+		}
+		_, _ = w.Write([]byte("plain text"))
+	}))
+	defer ts.Close()
+	c := Client{}
+	got, err := c.GetString(context.Background(), ts.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "plain text" {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", "plain text", got)
+	}
+	b, err := c.GetBytes(context.Background(), ts.URL+"/fail?token=secret", nil)
+	var herr *Error
+	if !errors.As(err, &herr) || herr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected Error, got %v", err)
+	}
+	if want := "GET " + ts.URL + "/fail?token=REDACTED: http 400 (request id abc-SJC)\nbad"; err.Error() != want {
+		t.Errorf("Unexpected\nwant: %q\ngot:  %q", want, err.Error())
+	}
+	if herr.RequestID != "abc-SJC" {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", "abc-SJC", herr.RequestID)
+	}
+	if string(b) != "bad" {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", "bad", string(b))
+	}
+}
+
+func TestClient_Get_html(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusBadGateway)
+		_, _ = w.Write([]byte("<!DOCTYPE html>\n<HTML><head><TITLE>\n  502 Bad &amp; Gateway\n</TITLE></head><body>nginx</body></HTML>"))
+	}))
+	defer ts.Close()
+	var out struct{}
+	err := (&Client{}).Get(context.Background(), ts.URL, nil, &out)
+	var herr *HTMLPageError
+	if !errors.As(err, &herr) {
+		t.Fatalf("expected HTMLPageError, got %v", err)
+	}
+	if want := "server returned an HTML page, status 502, title: \"502 Bad & Gateway\"\nGET " + ts.URL + ": http 502"; err.Error() != want {
+		t.Errorf("Unexpected\nwant: %q\ngot:  %q", want, err.Error())
+	}
+}
+
+func TestHTMLTitle(t *testing.T) {
+	t.Parallel()
+	data := []struct {
+		in   string
+		want string
+	}{
+		{"<html><title>Hi</title></html>", "Hi"},
+		{"<html><title lang=\"en\">Ünïcode ÀB</title>", "Ünïcode ÀB"},
+		{"<html>\xff<TITLE>X</TITLE>", "X"},
+		{"<html><title>unterminated", ""},
+		{"<html></html>", ""},
+	}
+	for _, line := range data {
+		if got := htmlTitle([]byte(line.in)); got != line.want {
+			t.Errorf("%q: Unexpected\nwant: %q\ngot:  %q", line.in, line.want, got)
+		}
+	}
+}
+
+func TestClient_empty_body(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/204":
+			w.WriteHeader(http.StatusNoContent)
+		case "/500":
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer ts.Close()
+	out := struct {
+		A int `json:"a"`
+	}{A: 1}
+	c := Client{}
+	for _, p := range []string{"/204", "/200"} {
+		if err := c.Get(context.Background(), ts.URL+p, nil, &out); err != nil {
+			t.Fatalf("%s: %v", p, err)
+		}
+	}
+	if out.A != 1 {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", 1, out.A)
+	}
+	err := c.Get(context.Background(), ts.URL+"/500", nil, &out)
+	var herr *Error
+	if !errors.Is(err, ErrEmptyBody) || !errors.As(err, &herr) || herr.StatusCode != 500 {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	c.DisallowEmptyBody = true
+	if err := c.Get(context.Background(), ts.URL+"/204", nil, &out); !errors.Is(err, ErrEmptyBody) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestClient_truncated(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Content-Length", "100")
+		_, _ = w.Write([]byte(`{"output":`))
+		w.(http.Flusher).Flush()
+		// Close the connection early.
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		_ = conn.Close()
+	}))
+	defer ts.Close()
+	var out struct {
+		Output string `json:"output"`
+	}
+	err := (&Client{}).Get(context.Background(), ts.URL, nil, &out)
+	var terr *TruncatedResponseError
+	if !errors.As(err, &terr) {
+		t.Fatalf("expected TruncatedResponseError, got %v", err)
+	}
+	if terr.Received != 10 || terr.Expected != 100 {
+		t.Errorf("Unexpected %+v", terr)
+	}
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestClient_GetOptional(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":"not found"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"output":"data"}`))
+	}))
+	defer ts.Close()
+	var out struct {
+		Output string `json:"output"`
+	}
+	c := Client{}
+	found, err := c.GetOptional(context.Background(), ts.URL+"/missing", nil, &out)
+	if err != nil || found {
+		t.Fatalf("Unexpected %t %v", found, err)
+	}
+	found, err = c.GetOptional(context.Background(), ts.URL+"/present", nil, &out)
+	if err != nil || !found {
+		t.Fatalf("Unexpected %t %v", found, err)
+	}
+	if out.Output != "data" {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", "data", out.Output)
+	}
+	if _, err = c.GetOptional(context.Background(), "bad\x00url", nil, &out); err == nil {
+		t.Error("expected error")
+	}
+}
+
 func TestClient_Get_error_url(t *testing.T) {
 	if err := (&Client{}).Get(context.Background(), "bad\x00url", nil, nil); err == nil {
 		t.Fatal("expected error")
 	}
 }
 
-func TestClient_Get_error_bad_decode(t *testing.T) {
+func TestClient_Get_error_bad_decode(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write([]byte(`not json`))
+	}))
+	defer ts.Close()
+	var out struct {
+		Output string `json:"output"`
+	}
+	c := Client{}
+	err := c.Get(context.Background(), ts.URL, nil, &out)
+	if err != nil {
+		var herr *Error
+		if !errors.As(err, &herr) {
+			t.Error("expected Error")
+		}
+		var jerr *json.SyntaxError
+		if !errors.As(err, &jerr) {
+			t.Error("expected json.SyntaxError")
+		}
+		want := "invalid character 'o' in literal null (expecting 'u')\nGET " + ts.URL + ": http 200\nnot json"
+		if err.Error() != want {
+			t.Errorf("failed\nwant: %q\ngot:  %q", want, err)
+		}
+	}
+}
+
+func TestClient_Get_error_decode_unexpected_field(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write([]byte(`{"output":"data"}`))
+	}))
+	defer ts.Close()
+
+	var out struct {
+		Different string `json:"different"`
+	}
+	c := Client{}
+	if err := c.Get(context.Background(), ts.URL, nil, &out); err != nil {
+		var herr *json.SyntaxError
+		if errors.As(err, &herr) {
+			t.Error("unexpected Error", herr)
+		}
+		var jerr *json.SyntaxError
+		if errors.As(err, &jerr) {
+			t.Error("unexpected json.SyntaxError", jerr)
+		}
+		want := "unknown field *struct { Different string \"json:\\\"different\\\"\" }.output of type string with value \"data\"\nGET " + ts.URL + ": http 200\n{\"output\":\"data\"}"
+		if got := err.Error(); got != want {
+			t.Errorf("unexpected error\nwant: %q\ngot:  %q", want, got)
+		}
+	}
+
+	// Same but with lenient for this call only.
+	if err := c.Get(OverrideLenient(context.Background(), true), ts.URL, nil, &out); err != nil {
+		t.Error(err)
+	}
+
+	// Same but with lenient.
+	c.Lenient = true
+	if err := c.Get(context.Background(), ts.URL, nil, &out); err != nil {
+		t.Error(err)
+	}
+	if err := c.Get(OverrideLenient(context.Background(), false), ts.URL, nil, &out); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestClient_Head(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "HEAD" && r.URL.Path == "/exists":
+			w.Header().Set("ETag", `"1"`)
+		case r.Method == "OPTIONS":
+			w.Header().Set("Allow", "GET, POST")
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+	c := Client{}
+	ctx := context.Background()
+	status, hdr, err := c.Head(ctx, ts.URL+"/exists", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != 200 || hdr.Get("ETag") != `"1"` {
+		t.Errorf("Unexpected %d %v", status, hdr)
+	}
+	if status, _, err = c.Head(ctx, ts.URL+"/missing", nil); err != nil || status != 404 {
+		t.Errorf("Unexpected %d %v", status, err)
+	}
+	status, hdr, err = c.Options(ctx, ts.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != 204 || hdr.Get("Allow") != "GET, POST" {
+		t.Errorf("Unexpected %d %v", status, hdr)
+	}
+	if _, _, err = c.Head(ctx, "bad\x00url", nil); err == nil {
+		t.Error("expected error")
+	}
+}
+
+//
+
+func TestClient_Post(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			Input string `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			t.Error(err)
+		}
+		if in.Input != "data" {
+			t.Errorf("Unexpected\nwant: %v\ngot:  %v", "data", in.Input)
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_, _ = w.Write([]byte(`{"output":"data"}`))
+	}))
+	defer ts.Close()
+	in := map[string]string{"input": "data"}
+	var out struct {
+		Output string `json:"output"`
+	}
+	c := Client{}
+	err := c.Post(context.Background(), ts.URL, nil, in, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Output != "data" {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", "data", out.Output)
+	}
+}
+
+func TestClient_Post_progress(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_, _ = w.Write([]byte(`{"output":"data"}`))
+	}))
+	defer ts.Close()
+	var sent, sentTotal, received, receivedTotal int64
+	c := Client{
+		UploadProgress:   func(n, total int64) { sent, sentTotal = n, total },
+		DownloadProgress: func(n, total int64) { received, receivedTotal = n, total },
+	}
+	var out struct {
+		Output string `json:"output"`
+	}
+	if err := c.Post(context.Background(), ts.URL, nil, map[string]string{"input": "data"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if sent != 17 || sentTotal != 17 {
+		t.Errorf("Unexpected upload progress: %d/%d", sent, sentTotal)
+	}
+	if received != 17 || receivedTotal != 17 {
+		t.Errorf("Unexpected download progress: %d/%d", received, receivedTotal)
+	}
+}
+
+func TestClient_Post_idempotency_key(t *testing.T) {
+	t.Parallel()
+	var keys []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+	c := Client{IdempotencyKey: true}
+	ctx := context.Background()
+	out := map[string]string{}
+	if err := c.Post(ctx, ts.URL, nil, map[string]string{}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(ctx, ts.URL, nil, &out); err != nil {
+		t.Fatal(err)
+	}
+	hdr := http.Header{"Idempotency-Key": []string{"mine"}}
+	if err := c.Post(ctx, ts.URL, hdr, map[string]string{}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 3 {
+		t.Fatalf("Unexpected %q", keys)
+	}
+	if len(keys[0]) != 36 || keys[0][14] != '4' {
+		t.Errorf("Unexpected UUID %q", keys[0])
+	}
+	if keys[1] != "" {
+		t.Errorf("Unexpected key on GET %q", keys[1])
+	}
+	if keys[2] != "mine" {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", "mine", keys[2])
+	}
+}
+
+func TestClient_Post_redirect(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/new", http.StatusTemporaryRedirect)
+			return
+		}
+		var in struct {
+			Input string `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			t.Error(err)
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(map[string]string{"output": in.Input})
+	}))
+	defer ts.Close()
+	var calls int
+	c := Client{UploadProgress: func(n, total int64) { calls++ }}
+	var out struct {
+		Output string `json:"output"`
+	}
+	if err := c.Post(context.Background(), ts.URL+"/old", nil, map[string]string{"input": "data"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Output != "data" {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", "data", out.Output)
+	}
+	if calls != 2 {
+		t.Errorf("Expected progress for both the original and the replayed body, got %d calls", calls)
+	}
+}
+
+func TestClient_Post_retry_on_429(t *testing.T) {
+	t.Parallel()
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		if string(b) != "{\"input\":\"data\"}\n" {
+			t.Errorf("Unexpected body %q", b)
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		switch r.URL.Path {
+		case "/later":
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"error":"slow down"}`))
+			return
+		case "/once":
+			if calls.Add(1) == 1 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+				_, _ = w.Write([]byte(`{"error":"slow down"}`))
+				return
+			}
+		}
+		_, _ = w.Write([]byte(`{"output":"data"}`))
+	}))
+	defer ts.Close()
+	var attempts []int
+	c := Client{
+		RetryOn429: 2,
+		Observer: ObserverFunc(func(method, host string, status int, d time.Duration, attempt int) {
+			attempts = append(attempts, attempt)
+		}),
+	}
+	in := map[string]string{"input": "data"}
+	var out struct {
+		Output string `json:"output"`
+	}
+	if err := c.Post(context.Background(), ts.URL+"/once", nil, in, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Output != "data" {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", "data", out.Output)
+	}
+	if !reflect.DeepEqual(attempts, []int{1, 2}) {
+		t.Errorf("Unexpected attempts %v", attempts)
+	}
+	if r := c.Stats().Retries; r != 1 {
+		t.Errorf("Unexpected retries %d", r)
+	}
+
+	// The wait doesn't fit in the deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	resp, err := c.PostRequest(ctx, ts.URL+"/later", nil, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("Unexpected status %d", resp.StatusCode)
+	}
+}
+
+func TestClient_Retry(t *testing.T) {
+	t.Parallel()
+	var calls atomic.Int32
+	var mu sync.Mutex
+	var keys []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		if b, _ := io.ReadAll(r.Body); string(b) != "{\"input\":\"data\"}\n" {
+			t.Errorf("Unexpected body %q", b)
+		}
+		mu.Lock()
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		switch n {
+		case 1:
+			// Close the connection without a response.
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			_ = conn.Close()
+		case 2:
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`{"error":"down"}`))
+		default:
+			_, _ = w.Write([]byte(`{"output":"data"}`))
+		}
+	}))
+	defer ts.Close()
+	c := Client{
+		Retry: retry.MaxRetries(2, retry.Any(
+			retry.ServerErrors(retry.Constant(time.Millisecond)),
+			retry.TransportErrors(retry.Constant(time.Millisecond)),
+		)),
+	}
+	in := map[string]string{"input": "data"}
+	var out struct {
+		Output string `json:"output"`
+	}
+	// A POST without Idempotency-Key may have been processed, it is not sent
+	// twice.
+	if err := c.Post(context.Background(), ts.URL, nil, in, &out); err == nil {
+		t.Fatal("expected error")
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("Unexpected calls %d", n)
+	}
+	calls.Store(0)
+	mu.Lock()
+	keys = nil
+	mu.Unlock()
+	c.IdempotencyKey = true
+	if err := c.Post(context.Background(), ts.URL, nil, in, &out); err != nil {
+		t.Fatal(err)
+	}
+	if n := calls.Load(); n != 3 || out.Output != "data" {
+		t.Errorf("Unexpected %d %q", n, out.Output)
+	}
+	mu.Lock()
+	if len(keys) != 3 || keys[0] == "" || keys[1] != keys[0] || keys[2] != keys[0] {
+		t.Errorf("Unexpected Idempotency-Key %q", keys)
+	}
+	mu.Unlock()
+	if r := c.Stats().Retries; r != 2 {
+		t.Errorf("Unexpected retries %d", r)
+	}
+	// Out of retries, the last error is returned.
+	calls.Store(1)
+	c.Retry = retry.MaxRetries(0, c.Retry)
+	var herr *Error
+	if err := c.Post(context.Background(), ts.URL, nil, in, &out); !errors.As(err, &herr) || herr.StatusCode != 503 {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestClient_RetryMethods(t *testing.T) {
 	t.Parallel()
+	var calls atomic.Int32
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		w.Write([]byte(`not json`))
+		w.Header().Set("Content-Type", "application/json")
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_, _ = w.Write([]byte(`{}`))
 	}))
 	defer ts.Close()
-	var out struct {
-		Output string `json:"output"`
+	c := Client{
+		Retry:        retry.MaxRetries(1, retry.ServerErrors(retry.Constant(time.Millisecond))),
+		RetryMethods: []string{http.MethodPut},
 	}
-	c := Client{}
-	err := c.Get(context.Background(), ts.URL, nil, &out)
+	// PUT is retried as configured.
+	resp, err := c.Request(context.Background(), http.MethodPut, ts.URL, nil, map[string]string{"a": "b"})
 	if err != nil {
-		var herr *Error
-		if !errors.As(err, &herr) {
-			t.Error("expected Error")
-		}
-		var jerr *json.SyntaxError
-		if !errors.As(err, &jerr) {
-			t.Error("expected json.SyntaxError")
-		}
-		want := "invalid character 'o' in literal null (expecting 'u')\nhttp 200\nnot json"
-		if err.Error() != want {
-			t.Errorf("failed\nwant: %q\ngot:  %q", want, err)
-		}
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != 200 || calls.Load() != 2 {
+		t.Errorf("Unexpected %d after %d calls", resp.StatusCode, calls.Load())
+	}
+	// GET isn't in RetryMethods anymore.
+	calls.Store(0)
+	if resp, err = c.GetRequest(context.Background(), ts.URL, nil); err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != 503 || calls.Load() != 1 {
+		t.Errorf("Unexpected %d after %d calls", resp.StatusCode, calls.Load())
 	}
 }
 
-func TestClient_Get_error_decode_unexpected_field(t *testing.T) {
+func TestClient_RetryBudget(t *testing.T) {
 	t.Parallel()
+	var calls atomic.Int32
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		w.Write([]byte(`{"output":"data"}`))
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(`{"error":"down"}`))
 	}))
 	defer ts.Close()
-
-	var out struct {
-		Different string `json:"different"`
+	c := Client{
+		Retry:       retry.MaxRetries(3, retry.ServerErrors(retry.Constant(time.Millisecond))),
+		RetryBudget: retry.NewBudget(0.1, 2),
 	}
-	c := Client{}
-	if err := c.Get(context.Background(), ts.URL, nil, &out); err != nil {
-		var herr *json.SyntaxError
-		if errors.As(err, &herr) {
-			t.Error("unexpected Error", herr)
-		}
-		var jerr *json.SyntaxError
-		if errors.As(err, &jerr) {
-			t.Error("unexpected json.SyntaxError", jerr)
-		}
-		want := "unknown field *struct { Different string \"json:\\\"different\\\"\" }.output of type string with value \"data\"\nhttp 200\n{\"output\":\"data\"}"
-		if got := err.Error(); got != want {
-			t.Errorf("unexpected error\nwant: %q\ngot:  %q", want, got)
-		}
+	// The budget allows 2 retries, not the 3 of the policy.
+	var berr *retry.BudgetError
+	if err := c.Get(context.Background(), ts.URL, nil, &struct{}{}); !errors.As(err, &berr) {
+		t.Fatalf("Unexpected error: %v", err)
 	}
-
-	// Same but with lenient.
-	c.Lenient = true
-	if err := c.Get(context.Background(), ts.URL, nil, &out); err != nil {
-		t.Error(err)
+	if berr.Attempts != 3 || berr.StatusCode != 503 {
+		t.Errorf("Unexpected %+v", berr)
+	}
+	// The last response is kept.
+	var herr *Error
+	if !errors.As(berr, &herr) || herr.StatusCode != 503 || string(herr.ResponseBody) != `{"error":"down"}` {
+		t.Errorf("Unexpected %v", berr.Err)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("Unexpected calls %d", n)
+	}
+	// Exhausted, the next request isn't retried at all.
+	calls.Store(0)
+	if err := c.Get(context.Background(), ts.URL, nil, &struct{}{}); !errors.As(err, &berr) || berr.Attempts != 1 {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("Unexpected calls %d", n)
 	}
 }
 
-//
-
-func TestClient_Post(t *testing.T) {
+func TestClient_Post_expect_continue(t *testing.T) {
 	t.Parallel()
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		var in struct {
-			Input string `json:"input"`
-		}
-		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
-			t.Error(err)
-		}
-		if in.Input != "data" {
-			t.Errorf("Unexpected\nwant: %v\ngot:  %v", "data", in.Input)
-		}
+		_, _ = io.Copy(io.Discard, r.Body)
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		_, _ = w.Write([]byte(`{"output":"data"}`))
+		_ = json.NewEncoder(w).Encode(map[string]string{"expect": r.Header.Get("Expect")})
 	}))
 	defer ts.Close()
-	in := map[string]string{"input": "data"}
+	c := Client{ExpectContinueSize: 20}
 	var out struct {
-		Output string `json:"output"`
+		Expect string `json:"expect"`
 	}
-	c := Client{}
-	err := c.Post(context.Background(), ts.URL, nil, in, &out)
-	if err != nil {
+	if err := c.Post(context.Background(), ts.URL, nil, map[string]string{"input": "small"}, &out); err != nil {
 		t.Fatal(err)
 	}
-	if out.Output != "data" {
-		t.Errorf("Unexpected\nwant: %v\ngot:  %v", "data", out.Output)
+	if out.Expect != "" {
+		t.Errorf("Unexpected %q", out.Expect)
+	}
+	if err := c.Post(context.Background(), ts.URL, nil, map[string]string{"input": "larger payload"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Expect != "100-continue" {
+		t.Errorf("Unexpected %q", out.Expect)
 	}
 }
 
@@ -178,6 +1133,340 @@ func TestClient_Post_error_url(t *testing.T) {
 	}
 }
 
+func TestClient_Build(t *testing.T) {
+	c := Client{}
+	hdr := http.Header{"X-Test": []string{"value"}}
+	req, err := c.Build(context.Background(), "PATCH", "http://localhost/", hdr, map[string]string{"input": "<data>"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Method != "PATCH" {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", "PATCH", req.Method)
+	}
+	if h := req.Header.Get("X-Test"); h != "value" {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", "value", h)
+	}
+	if h := req.Header.Get("Content-Type"); h != "application/json; charset=utf-8" {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", "application/json; charset=utf-8", h)
+	}
+	b, err := io.ReadAll(req.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "{\"input\":\"<data>\"}\n"; string(b) != want {
+		t.Errorf("Unexpected\nwant: %q\ngot:  %q", want, b)
+	}
+}
+
+func TestClient_Build_prepared(t *testing.T) {
+	c := Client{IdempotencyKey: true, ExpectContinueSize: 1, DenyHeaders: []string{"X-Debug"}, RequireHTTPS: true}
+	hdr := http.Header{"X-Debug": []string{"1"}}
+	req, err := c.Build(context.Background(), "POST", "https://example.com/", hdr, map[string]string{"a": "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Header.Get("Idempotency-Key") == "" {
+		t.Error("missing Idempotency-Key")
+	}
+	if h := req.Header.Get("Expect"); h != "100-continue" {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", "100-continue", h)
+	}
+	if h := req.Header.Get("X-Debug"); h != "" {
+		t.Errorf("Unexpected X-Debug: %q", h)
+	}
+	_, err = c.Build(context.Background(), "GET", "http://example.com/", nil, nil)
+	var ierr *InsecureURLError
+	if !errors.As(err, &ierr) {
+		t.Fatalf("Unexpected error %v", err)
+	}
+}
+
+func TestClient_Build_raw(t *testing.T) {
+	const want = `{"input":"data"}`
+	tests := []struct {
+		name string
+		in   any
+	}{
+		{"json.RawMessage", json.RawMessage(want)},
+		{"[]byte", []byte(want)},
+		{"io.Reader", strings.NewReader(want)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := (&Client{}).Build(context.Background(), "POST", "http://localhost/", nil, tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if req.ContentLength != int64(len(want)) {
+				t.Errorf("Unexpected\nwant: %v\ngot:  %v", len(want), req.ContentLength)
+			}
+			b, err := io.ReadAll(req.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != want {
+				t.Errorf("Unexpected\nwant: %q\ngot:  %q", want, b)
+			}
+		})
+	}
+}
+
+func TestClient_Build_error_url(t *testing.T) {
+	if _, err := (&Client{}).Build(context.Background(), "GET", "bad\x00url", nil, nil); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestError_Error(t *testing.T) {
+	tests := []struct {
+		name string
+		body []byte
+		want string
+	}{
+		{"empty", nil, "http 500\n"},
+		{"text", []byte("line1\n\tline2 é"), "http 500\nline1\n\tline2 é"},
+		{"binary", []byte("a\x00b\xffc\u200b"), "http 500\na\\x00b\\xffc\\u200b"},
+		{"truncated", bytes.Repeat([]byte("a"), 4100), "http 500\n" + strings.Repeat("a", 4096) + "… (4 more bytes)"},
+		{"truncated rune", append(bytes.Repeat([]byte("a"), 4095), "éé"...), "http 500\n" + strings.Repeat("a", 4095) + "… (4 more bytes)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &Error{ResponseBody: tt.body, StatusCode: 500, PrintBody: true}
+			if got := e.Error(); got != tt.want {
+				t.Errorf("Unexpected\nwant: %q\ngot:  %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"429", &Error{StatusCode: 429}, true},
+		{"500", &Error{StatusCode: 500}, false},
+		{"502", &Error{StatusCode: 502}, true},
+		{"503 joined", errors.Join(errors.New("decode"), &Error{StatusCode: 503}), true},
+		{"504", fmt.Errorf("wrapped: %w", &Error{StatusCode: 504}), true},
+		{"404", &Error{StatusCode: 404}, false},
+		{"canceled", &url.Error{Op: "Get", URL: "http://localhost", Err: context.Canceled}, false},
+		{"deadline", context.DeadlineExceeded, false},
+		{"refused", &url.Error{Op: "Get", URL: "http://localhost", Err: &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}}, true},
+		{"destination", &url.Error{Op: "Get", URL: "http://localhost", Err: &net.OpError{Op: "dial", Err: &DestinationError{Addr: "127.0.0.1:80"}}}, false},
+		{"x509", &url.Error{Op: "Get", URL: "https://localhost", Err: &tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}}}, false},
+		{"scheme", &url.Error{Op: "Get", URL: "ftp://localhost", Err: errors.New(`unsupported protocol scheme "ftp"`)}, false},
+		{"reset", fmt.Errorf("read: %w", syscall.ECONNRESET), true},
+		{"unexpected eof", io.ErrUnexpectedEOF, true},
+		{"other", errors.New("other"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryable(tt.err); got != tt.want {
+				t.Errorf("Unexpected\nwant: %v\ngot:  %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestIsStatus(t *testing.T) {
+	err := fmt.Errorf("wrapped: %w", errors.Join(errors.New("decode"), &Error{StatusCode: 404}))
+	if !IsStatus(err, 404) || !IsNotFound(err) {
+		t.Error("expected 404")
+	}
+	if IsUnauthorized(err) || IsTooManyRequests(err) {
+		t.Error("unexpected match")
+	}
+	if !IsUnauthorized(&Error{StatusCode: 401}) {
+		t.Error("expected 401")
+	}
+	if !IsTooManyRequests(&Error{StatusCode: 429}) {
+		t.Error("expected 429")
+	}
+	if IsStatus(errors.New("404"), 404) || IsStatus(nil, 404) {
+		t.Error("unexpected match")
+	}
+}
+
+func TestError_StatusClass(t *testing.T) {
+	err := fmt.Errorf("wrapped: %w", errors.Join(errors.New("decode"), &Error{StatusCode: 503}))
+	if !errors.Is(err, ErrStatusClass5xx) || errors.Is(err, ErrStatusClass4xx) || errors.Is(err, ErrStatusClass3xx) {
+		t.Error("expected 5xx only")
+	}
+	herr := &Error{StatusCode: 404}
+	if herr.StatusClass() != 4 || !herr.IsClientError() || herr.IsServerError() {
+		t.Errorf("Unexpected %d", herr.StatusClass())
+	}
+	if !errors.Is(herr, ErrStatusClass4xx) {
+		t.Error("expected 4xx")
+	}
+	if !(&Error{StatusCode: 500}).IsServerError() {
+		t.Error("expected 5xx")
+	}
+}
+
+func TestDecodeResponse_lenient(t *testing.T) {
+	newResp := func() *http.Response {
+		return &http.Response{StatusCode: 400, Status: "400 Bad Request", Body: io.NopCloser(strings.NewReader(`{"error":"bad","vendor":1}`))}
+	}
+	var out struct {
+		Message string `json:"message"`
+	}
+	var fallback struct {
+		Error string `json:"error"`
+	}
+	if i, _ := DecodeResponse(newResp(), &out, &fallback); i != -1 {
+		t.Fatalf("Unexpected\nwant: %v\ngot:  %v", -1, i)
+	}
+	i, err := DecodeResponse(newResp(), &out, Lenient(&fallback))
+	if i != 1 {
+		t.Fatalf("Unexpected\nwant: %v\ngot:  %v", 1, i)
+	}
+	if !IsStatus(err, 400) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if fallback.Error != "bad" {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", "bad", fallback.Error)
+	}
+}
+
+func TestDecodeResponse2(t *testing.T) {
+	type success struct {
+		Message string `json:"message"`
+	}
+	type failure struct {
+		Error string `json:"error"`
+	}
+	type other struct {
+		Code int `json:"code"`
+	}
+	newResp := func(body string) *http.Response {
+		return &http.Response{StatusCode: 200, Status: "200 OK", Body: io.NopCloser(strings.NewReader(body))}
+	}
+	ok, ko, err := DecodeResponse2[success, failure](newResp(`{"message":"hi"}`))
+	if err != nil || ok == nil || ko != nil || ok.Message != "hi" {
+		t.Errorf("Unexpected %v %v %v", ok, ko, err)
+	}
+	ok, ko, err = DecodeResponse2[success, failure](newResp(`{"error":"bad"}`))
+	if !IsStatus(err, 200) || ok != nil || ko == nil || ko.Error != "bad" {
+		t.Errorf("Unexpected %v %v %v", ok, ko, err)
+	}
+	ok, ko, err = DecodeResponse2[success, failure](newResp(`{"code":1}`))
+	if err == nil || ok != nil || ko != nil {
+		t.Errorf("Unexpected %v %v %v", ok, ko, err)
+	}
+	ok, ko, o, err := DecodeResponse3[success, failure, other](newResp(`{"code":1}`))
+	if !IsStatus(err, 200) || ok != nil || ko != nil || o == nil || o.Code != 1 {
+		t.Errorf("Unexpected %v %v %v %v", ok, ko, o, err)
+	}
+}
+
+func TestDecodeResponseBody(t *testing.T) {
+	const body = `{"message":"hi"}`
+	resp := &http.Response{StatusCode: 200, Status: "200 OK", Body: io.NopCloser(strings.NewReader(body))}
+	var out struct {
+		Message string `json:"message"`
+	}
+	i, b, err := DecodeResponseBody(resp, &out)
+	if i != 0 || err != nil {
+		t.Fatalf("Unexpected result %d: %v", i, err)
+	}
+	if string(b) != body {
+		t.Errorf("Unexpected\nwant: %q\ngot:  %q", body, b)
+	}
+	if out.Message != "hi" {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", "hi", out.Message)
+	}
+}
+
+type testAPIError struct {
+	Message string `json:"message"`
+}
+
+func (t *testAPIError) Error() string {
+	return t.Message
+}
+
+func TestError_Unwrap(t *testing.T) {
+	newResp := func() *http.Response {
+		return &http.Response{StatusCode: 403, Status: "403 Forbidden", Body: io.NopCloser(strings.NewReader(`{"message":"denied"}`))}
+	}
+	var out struct {
+		Data string `json:"data"`
+	}
+	var apiErr testAPIError
+	check := func(err error) {
+		t.Helper()
+		var target *testAPIError
+		if !errors.As(err, &target) {
+			t.Fatalf("expected testAPIError, got %v", err)
+		}
+		if target != &apiErr || target.Message != "denied" {
+			t.Errorf("Unexpected %+v", target)
+		}
+	}
+	i, err := DecodeResponse(newResp(), &out, Lenient(&apiErr))
+	if i != 1 {
+		t.Fatalf("Unexpected\nwant: %v\ngot:  %v", 1, i)
+	}
+	check(err)
+	check(DecodeResponseByStatus(newResp(), map[int]any{403: &apiErr}, &out))
+	if err := (&Error{Decoded: &out}).Unwrap(); err != nil {
+		t.Errorf("Unexpected %v", err)
+	}
+}
+
+func TestDecodeResponseByStatus(t *testing.T) {
+	type success struct {
+		Message string `json:"message"`
+	}
+	type failure struct {
+		Message string `json:"message"`
+		Code    int    `json:"code"`
+	}
+	newResp := func(code int, body string) *http.Response {
+		return &http.Response{StatusCode: code, Status: http.StatusText(code), Body: io.NopCloser(strings.NewReader(body))}
+	}
+	t.Run("success", func(t *testing.T) {
+		var ok success
+		var ko failure
+		if err := DecodeResponseByStatus(newResp(200, `{"message":"hi"}`), map[int]any{200: &ok}, &ko); err != nil {
+			t.Fatal(err)
+		}
+		if ok.Message != "hi" || ko.Message != "" {
+			t.Errorf("Unexpected %+v %+v", ok, ko)
+		}
+	})
+	t.Run("default", func(t *testing.T) {
+		var ok success
+		var ko failure
+		err := DecodeResponseByStatus(newResp(500, `{"message":"boom","code":3}`), map[int]any{200: &ok}, &ko)
+		if !IsStatus(err, 500) {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if ok.Message != "" || ko.Message != "boom" || ko.Code != 3 {
+			t.Errorf("Unexpected %+v %+v", ok, ko)
+		}
+	})
+	t.Run("no default", func(t *testing.T) {
+		var ok success
+		err := DecodeResponseByStatus(newResp(404, `not json`), map[int]any{200: &ok}, nil)
+		if !IsNotFound(err) {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+	t.Run("decode error", func(t *testing.T) {
+		var ok success
+		err := DecodeResponseByStatus(newResp(200, `{"other":1}`), map[int]any{200: &ok}, nil)
+		var uerr *UnknownFieldError
+		if !errors.As(err, &uerr) || !IsStatus(err, 200) {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+}
+
 func TestDecodeJSON(t *testing.T) {
 	var out struct {
 		Output string `json:"output"`
@@ -218,7 +1507,7 @@ func TestDecodeJSON_error(t *testing.T) {
 			"Ignored": "unexpected",
 		}
 		want := []error{&UnknownFieldError{StructType: "httpjson.Example", Field: "Ignored", FieldType: "string", FieldValue: "unexpected"}}
-		if got := findExtraKeysGeneric(example, example, data, ""); !errorsEqual(got, want) {
+		if got := findExtraKeysGeneric(example, example, data, "", "$"); !errorsEqual(got, want) {
 			t.Errorf("Unexpected\nwant: %v\ngot:  %v", want, got)
 		}
 	})
@@ -230,7 +1519,7 @@ func TestDecodeJSON_error(t *testing.T) {
 				"Extra2": "unexpected_nested",
 			},
 		}
-		got := findExtraKeysGeneric(example, example, data, "")
+		got := findExtraKeysGeneric(example, example, data, "", "$")
 		want := []error{&UnknownFieldError{StructType: "httpjson.Example", Field: "Nested.Extra2", FieldType: "string", FieldValue: "unexpected_nested"}}
 		if !errorsEqual(got, want) {
 			t.Errorf("Unexpected\nwant: %v\ngot:  %v", want, got)
@@ -310,6 +1599,19 @@ func TestFindExtraKeys(t *testing.T) {
 	type NestedByteContainer struct {
 		Inner ByteContainer
 	}
+	type WithTime struct {
+		When  time.Time  `json:"when"`
+		Maybe *time.Time `json:"maybe"`
+	}
+	type WithUnmarshaler struct {
+		Custom customUnmarshaler `json:"custom"`
+	}
+	type Opaque struct {
+		Raw  json.RawMessage `json:"raw"`
+		Any  any             `json:"any"`
+		Map  map[string]any  `json:"map"`
+		Subs map[string]Base `json:"subs"`
+	}
 	tests := []struct {
 		name   string
 		t      reflect.Type
@@ -317,6 +1619,42 @@ func TestFindExtraKeys(t *testing.T) {
 		prefix string
 		want   []error
 	}{
+		{
+			name: "Opaque fields",
+			t:    reflect.TypeOf(Opaque{}),
+			data: map[string]any{
+				"raw": map[string]any{"a": []any{1, "b"}},
+				"any": map[string]any{"c": map[string]any{"d": nil}},
+				"map": map[string]any{"e": []any{map[string]any{"f": true}}},
+			},
+		},
+		{
+			name: "Map of structs",
+			t:    reflect.TypeOf(Opaque{}),
+			data: map[string]any{"subs": map[string]any{"x": map[string]any{"Name": "a", "Value": 1}}},
+		},
+		{
+			name: "Map of structs (extra field)",
+			t:    reflect.TypeOf(Opaque{}),
+			data: map[string]any{"subs": map[string]any{"x": map[string]any{"Name": "a", "Bad": 1}}},
+			want: []error{&UnknownFieldError{StructType: "httpjson.Opaque", Field: "subs[x].Bad", FieldType: "int", FieldValue: 1}},
+		},
+		{
+			name: "time.Time",
+			t:    reflect.TypeOf(WithTime{}),
+			data: map[string]any{"when": "2025-01-02T03:04:05Z", "maybe": "2025-01-02T03:04:05Z"},
+		},
+		{
+			name: "json.Unmarshaler",
+			t:    reflect.TypeOf(WithUnmarshaler{}),
+			data: map[string]any{"custom": []any{"a", map[string]any{"b": 1}}},
+		},
+		{
+			name: "json.Unmarshaler (extra sibling)",
+			t:    reflect.TypeOf(WithUnmarshaler{}),
+			data: map[string]any{"custom": 1, "Bad": true},
+			want: []error{&UnknownFieldError{StructType: "httpjson.WithUnmarshaler", Field: "Bad", FieldType: "bool", FieldValue: true}},
+		},
 		{
 			name: "Valid nil",
 			t:    reflect.TypeOf([]NestedStruct{}),
@@ -475,6 +1813,16 @@ func TestFindExtraKeys(t *testing.T) {
 	}
 }
 
+// customUnmarshaler accepts any JSON value.
+type customUnmarshaler struct {
+	Inner string
+}
+
+func (c *customUnmarshaler) UnmarshalJSON(b []byte) error {
+	c.Inner = string(b)
+	return nil
+}
+
 func errorsEqual(a, b []error) bool {
 	if len(a) != len(b) {
 		return false
@@ -486,3 +1834,89 @@ func errorsEqual(a, b []error) bool {
 	}
 	return true
 }
+
+func TestSummarizeValue(t *testing.T) {
+	t.Parallel()
+	data := []struct {
+		v    any
+		want string
+	}{
+		{nil, "<nil>"},
+		{true, "true"},
+		{json.Number("1.5"), `"1.5"`},
+		{"abc", `"abc"`},
+		{"abcdef", `"abcd"… (2 more bytes)`},
+		{"abcé", `"abc"… (2 more bytes)`},
+		{map[string]any{"a": 1, "b": 2}, "object with 2 keys"},
+		{map[string]any{"a": 1}, "object with 1 key"},
+		{[]any{}, "array with 0 items"},
+		{[]map[string]any{{}}, "array with 1 item"},
+	}
+	for _, line := range data {
+		if got := summarizeValue(line.v, 4); got != line.want {
+			t.Errorf("Unexpected\nwant: %v\ngot:  %v", line.want, got)
+		}
+	}
+}
+
+func BenchmarkFindExtraKeys(b *testing.B) {
+	type Item struct {
+		Name  string `json:"name"`
+		Value int    `json:"value"`
+	}
+	var m map[string]any
+	if err := json.Unmarshal(benchBody, &m); err != nil {
+		b.Fatal(err)
+	}
+	t := reflect.TypeFor[struct {
+		Items []Item `json:"items"`
+	}]()
+	b.ReportAllocs()
+	for b.Loop() {
+		if errs := FindExtraKeys(t, m); len(errs) != 0 {
+			b.Fatal(errs)
+		}
+	}
+}
+
+func TestDecode_any(t *testing.T) {
+	t.Parallel()
+	type Out struct {
+		Name   string           `json:"name"`
+		Any    any              `json:"any"`
+		PAny   *any             `json:"pany"`
+		Meta   map[string]any   `json:"meta"`
+		List   []any            `json:"list"`
+		Nested map[string][]any `json:"nested"`
+		Iface  fmt.Stringer     `json:"iface"`
+	}
+	b := []byte(`{"name":"a","any":{"x":{"y":[1,{"z":null}]}},"pany":{"q":1},"meta":{"Foo":{"bar_baz":1.5e400}},"list":[{"a":1},2],"nested":{"k":[{"Deep":null}]}}`)
+	clients := []*Client{
+		{},
+		{CaseSensitive: true},
+		{StrictNumbers: true},
+		{DisallowNull: true},
+		{SnakeCase: true},
+		{DisallowDuplicateKeys: true},
+	}
+	for i, c := range clients {
+		var out Out
+		if err := c.decode(context.Background(), b, &out); err != nil {
+			t.Errorf("#%d: %v", i, err)
+		}
+		if m, ok := out.Any.(map[string]any); !ok || m["x"] == nil {
+			t.Errorf("#%d: Unexpected %#v", i, out.Any)
+		}
+	}
+	// Unknown fields next to an any subtree are still reported.
+	var out Out
+	err := decodeJSON([]byte(`{"meta":{"a":{"b":1}},"extra":{"c":1}}`), &out, false)
+	want := []error{&UnknownFieldError{StructType: "*httpjson.Out", Field: "extra", FieldType: "map[string]interface {}", FieldValue: map[string]any{"c": json.Number("1")}}}
+	if !errorsEqual(err.(interface{ Unwrap() []error }).Unwrap(), want) {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", want, err)
+	}
+	// A non-empty interface can't be decoded into.
+	if err = decodeJSON([]byte(`{"iface":{"a":1}}`), &out, false); err == nil || !strings.Contains(err.Error(), "fmt.Stringer") {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// jsonPathKey returns the JSONPath of member key of the object at path.
+//
+// It uses the dot notation when key is an identifier and the bracket notation
+// otherwise.
+func jsonPathKey(path, key string) string {
+	if isIdentifier(key) {
+		return path + "." + key
+	}
+	return path + "['" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(key) + "']"
+}
+
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		if r != '_' && r != '$' && (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (i == 0 || r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// setOffsets sets the Offset of the UnknownFieldError in errs by walking the
+// tokens of b. The offset of an object member is the one of its key.
+//
+// orig is the body before format tags were applied. When it differs from b,
+// the offsets are set to -1 instead.
+func setOffsets(orig, b []byte, errs []error) {
+	if !bytes.Equal(orig, b) {
+		for _, err := range errs {
+			if e, ok := err.(*UnknownFieldError); ok {
+				e.Offset = -1
+			}
+		}
+		return
+	}
+	want := make(map[string]*UnknownFieldError, len(errs))
+	for _, err := range errs {
+		if e, ok := err.(*UnknownFieldError); ok {
+			want[e.Path] = e
+		}
+	}
+	type frame struct {
+		path    string
+		object  bool
+		wantKey bool
+		member  string
+		index   int
+	}
+	var stack []*frame
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	for len(want) != 0 {
+		start := skipSeparators(b, d.InputOffset())
+		tok, err := d.Token()
+		if err != nil {
+			return
+		}
+		if tok == json.Delim('}') || tok == json.Delim(']') {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		path := "$"
+		if len(stack) != 0 {
+			switch top := stack[len(stack)-1]; {
+			case top.object && top.wantKey:
+				top.member = jsonPathKey(top.path, tok.(string))
+				top.wantKey = false
+				if e, ok := want[top.member]; ok {
+					e.Offset = start
+					delete(want, top.member)
+				}
+				continue
+			case top.object:
+				path = top.member
+				top.wantKey = true
+			default:
+				path = top.path + "[" + strconv.Itoa(top.index) + "]"
+				top.index++
+			}
+		}
+		if e, ok := want[path]; ok {
+			e.Offset = start
+			delete(want, path)
+		}
+		switch tok {
+		case json.Delim('{'):
+			stack = append(stack, &frame{path: path, object: true, wantKey: true})
+		case json.Delim('['):
+			stack = append(stack, &frame{path: path})
+		}
+	}
+}
+
+// skipSeparators returns the offset of the next token in b at or after i.
+func skipSeparators(b []byte, i int64) int64 {
+	for ; i < int64(len(b)); i++ {
+		switch b[i] {
+		case ' ', '\t', '\r', '\n', ',', ':':
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// clearOffsets sets to -1 the Offset of the *UnknownFieldError joined in err.
+func clearOffsets(err error) {
+	if j, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range j.Unwrap() {
+			if u, ok := e.(*UnknownFieldError); ok {
+				u.Offset = -1
+			}
+		}
+	} else if u, ok := err.(*UnknownFieldError); ok {
+		u.Offset = -1
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDecodeJSON_path(t *testing.T) {
+	t.Parallel()
+	type Item struct {
+		Metadata map[string]struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+		Tags []int `json:"tags"`
+	}
+	var out struct {
+		Items []Item `json:"items"`
+	}
+	b := []byte(`{"items": [{}, {"metadata": {"a b": {"name": "x", "new": 1}}}, {"tags": [1, 2]}, {"newField": true}]}`)
+	err := decodeJSON(b, &out, false)
+	var got []*UnknownFieldError
+	for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var u *UnknownFieldError
+		if !errors.As(e, &u) {
+			t.Fatalf("Unexpected error %T: %v", e, e)
+		}
+		got = append(got, u)
+	}
+	want := []struct {
+		path   string
+		offset int64
+	}{
+		{"$.items[1].metadata['a b'].new", int64(bytes.Index(b, []byte(`"new"`)))},
+		{"$.items[3].newField", int64(bytes.Index(b, []byte(`"newField"`)))},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Unexpected\nwant: %v\ngot:  %v", want, got)
+	}
+	for i, w := range want {
+		if got[i].Path != w.path || got[i].Offset != w.offset {
+			t.Errorf("Unexpected\nwant: %v @ %d\ngot:  %v @ %d", w.path, w.offset, got[i].Path, got[i].Offset)
+		}
+	}
+}
+
+func TestJSONPathKey(t *testing.T) {
+	t.Parallel()
+	data := []struct {
+		key  string
+		want string
+	}{
+		{"a", "$.a"},
+		{"_a1", "$._a1"},
+		{"1a", "$['1a']"},
+		{"a-b", "$['a-b']"},
+		{"it's", `$['it\'s']`},
+		{"", "$['']"},
+	}
+	for _, line := range data {
+		if got := jsonPathKey("$", line.key); got != line.want {
+			t.Errorf("Unexpected\nwant: %v\ngot:  %v", line.want, got)
+		}
+	}
+}
+
+func TestClient_decode_offset_rewritten(t *testing.T) {
+	t.Parallel()
+	var out struct {
+		A int `json:"a"`
+	}
+	data := []struct {
+		c    *Client
+		b    string
+		want int64
+	}{
+		{&Client{}, `{"a":1,"x":2}`, 7},
+		{&Client{Relaxed: true}, `{"a":1,"x":2}`, 7},
+		{&Client{Relaxed: true}, `{"a":1, // comment
+"x":2,}`, -1},
+	}
+	for _, line := range data {
+		err := line.c.decode(context.Background(), []byte(line.b), &out)
+		var u *UnknownFieldError
+		if !errors.As(err, &u) {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		if u.Offset != line.want {
+			t.Errorf("%q: Unexpected\nwant: %v\ngot:  %v", line.b, line.want, u.Offset)
+		}
+	}
+}
+
+func TestDecodeJSON_offset_format(t *testing.T) {
+	t.Parallel()
+	var out struct {
+		T time.Time `json:"t" httpjson:"format=unix"`
+	}
+	err := decodeJSON([]byte(`{"t":1700000000,"x":2}`), &out, false)
+	var u *UnknownFieldError
+	if !errors.As(err, &u) || u.Offset != -1 {
+		t.Fatalf("Unexpected error %v", err)
+	}
+}
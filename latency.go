@@ -0,0 +1,64 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// Latency is an http.RoundTripper delaying requests and responses, to test
+// timeout handling, progress indicators and alerting in non production
+// environments.
+//
+// The waits are interrupted when the request's context is done, in which case
+// the context error is returned.
+type Latency struct {
+	// Transport sends the requests. Defaults to http.DefaultTransport.
+	Transport http.RoundTripper
+	// Match selects the delayed requests. nil matches all of them.
+	Match func(*http.Request) bool
+	// Request is the wait before sending the request.
+	Request time.Duration
+	// Response is the wait after receiving the response headers, before
+	// returning the response.
+	Response time.Duration
+	// Jitter adds a random duration between 0 and Jitter to each wait.
+	Jitter time.Duration
+	// Clock is used to wait. Defaults to the real time.
+	Clock Clock
+}
+
+// RoundTrip implements http.RoundTripper.
+func (l *Latency) RoundTrip(req *http.Request) (*http.Response, error) {
+	t := transportOr(l.Transport)
+	if l.Match != nil && !l.Match(req) {
+		return t.RoundTrip(req)
+	}
+	if err := l.wait(req, l.Request); err != nil {
+		closeBody(req)
+		return nil, err
+	}
+	resp, err := t.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if err = l.wait(req, l.Response); err != nil {
+		_ = resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (l *Latency) wait(req *http.Request, d time.Duration) error {
+	if l.Jitter > 0 {
+		d += rand.N(l.Jitter)
+	}
+	if d <= 0 {
+		return nil
+	}
+	return clockOr(l.Clock).Sleep(req.Context(), d)
+}
@@ -0,0 +1,59 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLatency(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+	clk := &fakeClock{now: time.Unix(1000, 0)}
+	l := &Latency{
+		Match:    func(r *http.Request) bool { return strings.HasPrefix(r.URL.Path, "/slow") },
+		Request:  time.Second,
+		Response: 2 * time.Second,
+		Jitter:   time.Second,
+		Clock:    clk,
+	}
+	c := Client{Client: &http.Client{Transport: l}}
+	if err := c.Get(context.Background(), ts.URL+"/fast", nil, &struct{}{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(context.Background(), ts.URL+"/slow", nil, &struct{}{}); err != nil {
+		t.Fatal(err)
+	}
+	clk.mu.Lock()
+	sleeps := clk.sleeps
+	clk.mu.Unlock()
+	if len(sleeps) != 2 || sleeps[0] < time.Second || sleeps[0] >= 2*time.Second || sleeps[1] < 2*time.Second || sleeps[1] >= 3*time.Second {
+		t.Errorf("Unexpected sleeps %v", sleeps)
+	}
+}
+
+func TestLatency_canceled(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("unexpected request")
+	}))
+	defer ts.Close()
+	c := Client{Client: &http.Client{Transport: &Latency{Request: time.Hour}}}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if err := c.Get(ctx, ts.URL, nil, &struct{}{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Unexpected error %v", err)
+	}
+}
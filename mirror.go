@@ -0,0 +1,95 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Mirror is an http.RoundTripper sending a copy of the requests to a
+// secondary server, fire and forget, while returning the primary response.
+// Use it to validate a new backend against production traffic.
+//
+// Bodies without GetBody are read in memory to be sent twice.
+type Mirror struct {
+	// Transport sends the requests and their copies. Defaults to
+	// http.DefaultTransport.
+	Transport http.RoundTripper
+	// Target is the base URL the copies are sent to, e.g.
+	// "https://staging.example.com/v2". Its path prefixes the request's path.
+	Target string
+	// Sample is the fraction, between 0 and 1, of the requests mirrored. 0
+	// mirrors all of them.
+	Sample float64
+	// Timeout bounds each copy, which is not canceled with the original
+	// request. Defaults to 30 seconds.
+	Timeout time.Duration
+	// Report, when set, is called with the response or error of each copy,
+	// e.g. to log the differences. The body is closed once it returns.
+	Report func(resp *http.Response, err error)
+}
+
+// RoundTrip implements http.RoundTripper.
+func (m *Mirror) RoundTrip(req *http.Request) (*http.Response, error) {
+	t := transportOr(m.Transport)
+	if m.Sample > 0 && rand.Float64() >= m.Sample {
+		return t.RoundTrip(req)
+	}
+	target, err := url.Parse(m.Target)
+	if err != nil {
+		closeBody(req)
+		return nil, err
+	}
+	getBody := req.GetBody
+	if req.Body != nil && req.Body != http.NoBody && getBody == nil {
+		b, err := io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		getBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(b)), nil
+		}
+		r := req.Clone(req.Context())
+		r.Body, _ = getBody()
+		r.GetBody = getBody
+		req = r
+	}
+	timeout := m.Timeout
+	if timeout <= 0 {
+		timeout = defaultMirrorTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(req.Context()), timeout)
+	cp := req.Clone(ctx)
+	rebaseURL(cp, target)
+	if getBody != nil {
+		if cp.Body, err = getBody(); err != nil {
+			cancel()
+			closeBody(req)
+			return nil, err
+		}
+	}
+	go func() {
+		defer cancel()
+		resp, err := t.RoundTrip(cp)
+		if m.Report != nil {
+			m.Report(resp, err)
+		}
+		if err == nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+	}()
+	return t.RoundTrip(req)
+}
+
+// defaultMirrorTimeout is used when Mirror.Timeout is not set.
+const defaultMirrorTimeout = 30 * time.Second
@@ -0,0 +1,114 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"context"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMirror(t *testing.T) {
+	t.Parallel()
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"from":"primary","body":` + string(b) + `}`))
+	}))
+	defer primary.Close()
+	type got struct {
+		path string
+		body string
+	}
+	shadowed := make(chan got, 2)
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		shadowed <- got{r.URL.Path, string(b)}
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer shadow.Close()
+	reported := make(chan int, 2)
+	m := &Mirror{
+		Target: shadow.URL + "/v2/",
+		Report: func(resp *http.Response, err error) {
+			if err != nil {
+				t.Error(err)
+				reported <- 0
+				return
+			}
+			reported <- resp.StatusCode
+		},
+	}
+	c := Client{Client: &http.Client{Transport: m}}
+	var out struct {
+		From string `json:"from"`
+		Body struct {
+			A string `json:"a"`
+		} `json:"body"`
+	}
+	// The body is replayable.
+	if err := c.Post(context.Background(), primary.URL+"/items", nil, map[string]string{"a": "b"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.From != "primary" || out.Body.A != "b" {
+		t.Errorf("Unexpected %+v", out)
+	}
+	// The body must be read in memory.
+	if err := c.Post(context.Background(), primary.URL+"/raw", nil, io.MultiReader(strings.NewReader(`{"a":"c"}`)), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Body.A != "c" {
+		t.Errorf("Unexpected %+v", out)
+	}
+	// The copies are sent concurrently so they may arrive in any order.
+	copies := map[string]string{}
+	for range 2 {
+		g := <-shadowed
+		copies[g.path] = g.body
+		if s := <-reported; s != http.StatusTeapot {
+			t.Errorf("Unexpected status %d", s)
+		}
+	}
+	want := map[string]string{"/v2/items": "{\"a\":\"b\"}\n", "/v2/raw": `{"a":"c"}`}
+	if !maps.Equal(copies, want) {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", want, copies)
+	}
+}
+
+func TestMirror_escapedPath(t *testing.T) {
+	t.Parallel()
+	handler := func(paths chan<- string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			paths <- r.URL.EscapedPath()
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{}`))
+		}
+	}
+	primaryPaths := make(chan string, 1)
+	primary := httptest.NewServer(handler(primaryPaths))
+	defer primary.Close()
+	shadowPaths := make(chan string, 1)
+	shadow := httptest.NewServer(handler(shadowPaths))
+	defer shadow.Close()
+	c := Client{Client: &http.Client{Transport: &Mirror{Target: shadow.URL}}}
+	u, err := ExpandURL(primary.URL+"/users/{id}", map[string]string{"id": "a/b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(context.Background(), u, nil, &struct{}{}); err != nil {
+		t.Fatal(err)
+	}
+	want := "/users/a%2Fb"
+	if got := <-primaryPaths; got != want {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", want, got)
+	}
+	if got := <-shadowPaths; got != want {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", want, got)
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"iter"
+	"net/http"
+)
+
+const ndjsonContentType = "application/x-ndjson"
+
+// RequestNDJSON streams items as newline delimited JSON in the request body.
+//
+// The body is sent with chunked transfer encoding as items are produced; it is
+// never fully buffered in memory. This is useful for bulk ingestion endpoints.
+// To stream from a channel, wrap it in an iter.Seq.
+//
+// It initiates the requests and returns the response back for further processing.
+func RequestNDJSON[T any](ctx context.Context, c *Client, method, url string, hdr http.Header, items iter.Seq[T]) (*http.Response, error) {
+	pr, pw := io.Pipe()
+	req, err := http.NewRequestWithContext(ctx, method, url, pr)
+	if err != nil {
+		return nil, err
+	}
+	c.setHeaders(req, ndjsonContentType, hdr)
+	go func() {
+		e := json.NewEncoder(pw)
+		e.SetEscapeHTML(false)
+		for item := range items {
+			if err := e.Encode(item); err != nil {
+				// Either the item can't be encoded or the request body was closed.
+				_ = pw.CloseWithError(err)
+				return
+			}
+		}
+		_ = pw.Close()
+	}()
+	resp, err := c.do(req)
+	if err != nil {
+		// Unblock the encoder if the body wasn't consumed.
+		_ = pr.Close()
+	}
+	return resp, err
+}
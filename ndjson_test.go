@@ -0,0 +1,63 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestRequestNDJSON(t *testing.T) {
+	t.Parallel()
+	type item struct {
+		ID int `json:"id"`
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/x-ndjson" {
+			t.Errorf("Unexpected\nwant: %v\ngot:  %v", "application/x-ndjson", ct)
+		}
+		if r.ContentLength != -1 {
+			t.Errorf("expected chunked encoding, got length %d", r.ContentLength)
+		}
+		var got []int
+		s := bufio.NewScanner(r.Body)
+		for s.Scan() {
+			var i item
+			if err := json.Unmarshal(s.Bytes(), &i); err != nil {
+				t.Error(err)
+			}
+			got = append(got, i.ID)
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(map[string]int{"count": len(got)})
+	}))
+	defer ts.Close()
+
+	items := slices.Values([]item{{1}, {2}, {3}})
+	resp, err := RequestNDJSON(context.Background(), &Client{}, "POST", ts.URL, nil, items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out struct {
+		Count int `json:"count"`
+	}
+	if _, err = DecodeResponse(resp, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Count != 3 {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", 3, out.Count)
+	}
+}
+
+func TestRequestNDJSON_error_url(t *testing.T) {
+	if _, err := RequestNDJSON(context.Background(), &Client{}, "POST", "bad\x00url", nil, slices.Values([]int{1})); err == nil {
+		t.Fatal("expected error")
+	}
+}
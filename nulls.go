@@ -0,0 +1,53 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// NullFieldError is a JSON null for a field that cannot be nil.
+type NullFieldError struct {
+	StructType string
+	Field      string
+	FieldType  string
+}
+
+// Error implements the error interface.
+func (e *NullFieldError) Error() string {
+	return fmt.Sprintf("null field %s.%s of type %s", e.StructType, e.Field, e.FieldType)
+}
+
+// checkNulls returns joined *NullFieldError for all the nulls in b whose
+// destination in out cannot be nil.
+func checkNulls(b []byte, out any) error {
+	root := reflect.TypeOf(out)
+	if root == nil {
+		return nil
+	}
+	var v any
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if d.Decode(&v) != nil {
+		// Let the real decoder report the error.
+		return nil
+	}
+	errs := walkJSON(root, v, "", func(t reflect.Type, value any, prefix string) []error {
+		if value != nil {
+			return nil
+		}
+		switch t.Kind() {
+		case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice:
+			return nil
+		default:
+			return []error{&NullFieldError{StructType: root.String(), Field: prefix, FieldType: t.String()}}
+		}
+	})
+	return errors.Join(errs...)
+}
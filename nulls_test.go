@@ -0,0 +1,61 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestClient_decode_disallow_null(t *testing.T) {
+	type Inner struct {
+		Count int `json:"count"`
+	}
+	type Out struct {
+		Count   int            `json:"count"`
+		Ptr     *int           `json:"ptr"`
+		Any     any            `json:"any"`
+		List    []int          `json:"list"`
+		Map     map[string]int `json:"map"`
+		Inner   Inner          `json:"inner"`
+		When    time.Time      `json:"when"`
+		Nullish []*int         `json:"nullish"`
+	}
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"nilable", `{"ptr":null,"any":null,"list":null,"map":null,"nullish":[null]}`, ""},
+		{"scalar", `{"count":null}`, "null field *httpjson.Out.count of type int"},
+		{"struct", `{"inner":null}`, "null field *httpjson.Out.inner of type httpjson.Inner"},
+		{"nested", `{"inner":{"count":null}}`, "null field *httpjson.Out.inner.count of type int"},
+		{"element", `{"list":[1,null]}`, "null field *httpjson.Out.list[1] of type int"},
+		{"map value", `{"map":{"a":null}}`, "null field *httpjson.Out.map[a] of type int"},
+		{"leaf", `{"when":null}`, "null field *httpjson.Out.when of type time.Time"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Client{DisallowNull: true}
+			var out Out
+			err := c.decode(context.Background(), []byte(tt.data), &out)
+			if tt.want == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			var nerr *NullFieldError
+			if !errors.As(err, &nerr) {
+				t.Fatalf("expected NullFieldError, got %v", err)
+			}
+			if got := err.Error(); got != tt.want {
+				t.Errorf("Unexpected\nwant: %q\ngot:  %q", tt.want, got)
+			}
+		})
+	}
+}
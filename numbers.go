@@ -0,0 +1,97 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+)
+
+// NumberError is a JSON number that doesn't fit its Go field.
+type NumberError struct {
+	StructType string
+	Field      string
+	FieldType  string
+	Value      string
+	Reason     string
+}
+
+// Error implements the error interface.
+func (e *NumberError) Error() string {
+	return fmt.Sprintf("number %s.%s of type %s with value %s: %s", e.StructType, e.Field, e.FieldType, e.Value, e.Reason)
+}
+
+// checkNumbers returns joined *NumberError for all the numbers in b that do
+// not fit in their destination field in out.
+func checkNumbers(b []byte, out any) error {
+	t := reflect.TypeOf(out)
+	if t == nil {
+		return nil
+	}
+	var v any
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if d.Decode(&v) != nil {
+		// Let the real decoder report the error.
+		return nil
+	}
+	return errors.Join(findNumberErrors(t, t, v, "")...)
+}
+
+func findNumberErrors(root, t reflect.Type, value any, prefix string) []error {
+	return walkJSON(t, value, prefix, func(t reflect.Type, value any, prefix string) []error {
+		n, ok := value.(json.Number)
+		if !ok {
+			return nil
+		}
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		if reason := numberFits(t, string(n)); reason != "" {
+			return []error{&NumberError{StructType: root.String(), Field: prefix, FieldType: t.String(), Value: string(n), Reason: reason}}
+		}
+		return nil
+	})
+}
+
+// numberFits returns the reason why s doesn't fit in a value of type t, or ""
+// if it fits. Types other than numbers are ignored.
+func numberFits(t reflect.Type, s string) string {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if _, err := strconv.ParseInt(s, 10, t.Bits()); err != nil {
+			return integerReason(err)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if _, err := strconv.ParseUint(s, 10, t.Bits()); err != nil {
+			return integerReason(err)
+		}
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, t.Bits())
+		if err != nil {
+			return "overflow"
+		}
+		// Compare the exact decimal value with the shortest representation of
+		// the float.
+		want, ok1 := new(big.Rat).SetString(s)
+		got, ok2 := new(big.Rat).SetString(strconv.FormatFloat(f, 'g', -1, t.Bits()))
+		if ok1 && ok2 && want.Cmp(got) != 0 {
+			return "precision loss"
+		}
+	}
+	return ""
+}
+
+func integerReason(err error) string {
+	if errors.Is(err, strconv.ErrRange) {
+		return "overflow"
+	}
+	return "not an integer"
+}
@@ -0,0 +1,97 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClient_Get_strict_numbers(t *testing.T) {
+	t.Parallel()
+	type Item struct {
+		ID int32 `json:"id"`
+	}
+	type Out struct {
+		Small  int8             `json:"small"`
+		Count  uint             `json:"count"`
+		Ratio  float32          `json:"ratio"`
+		Big    float64          `json:"big"`
+		Items  []Item           `json:"items"`
+		Scores map[string]int16 `json:"scores"`
+		Any    any              `json:"any"`
+	}
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{
+			name: "valid",
+			body: `{"small":-128,"count":1,"ratio":0.1,"big":1e300,"items":[{"id":2147483647}],"scores":{"a":1},"any":1e999}`,
+		},
+		{
+			name: "overflow",
+			body: `{"small":128,"items":[{"id":1},{"id":2147483648}],"ratio":1e39}`,
+			want: []string{
+				"number *httpjson.Out.small of type int8 with value 128: overflow",
+				"number *httpjson.Out.items[1].id of type int32 with value 2147483648: overflow",
+				"number *httpjson.Out.ratio of type float32 with value 1e39: overflow",
+			},
+		},
+		{
+			name: "not an integer",
+			body: `{"count":1.5,"scores":{"a":-1e2}}`,
+			want: []string{
+				"number *httpjson.Out.count of type uint with value 1.5: not an integer",
+				"number *httpjson.Out.scores[a] of type int16 with value -1e2: not an integer",
+			},
+		},
+		{
+			name: "precision loss",
+			body: `{"ratio":0.123456789,"big":9007199254740993}`,
+			want: []string{
+				"number *httpjson.Out.ratio of type float32 with value 0.123456789: precision loss",
+				"number *httpjson.Out.big of type float64 with value 9007199254740993: precision loss",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json; charset=utf-8")
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer ts.Close()
+			c := Client{StrictNumbers: true}
+			var out Out
+			err := c.Get(context.Background(), ts.URL, nil, &out)
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			var nerr *NumberError
+			if !errors.As(err, &nerr) {
+				t.Fatalf("expected NumberError, got %v", err)
+			}
+			// The order is random since map iteration is random.
+			s := err.Error()
+			if n := strings.Count(s, "number *httpjson."); n != len(tt.want) {
+				t.Errorf("Unexpected %d errors: %s", n, s)
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(s, w) {
+					t.Errorf("missing %q in %q", w, s)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Offline is an http.RoundTripper failing the requests instantly with an
+// *OfflineError, so test suites and local development modes can guarantee no
+// request reaches the network.
+//
+// Combine it with a transport replaying recorded responses by setting
+// Transport and Allow.
+type Offline struct {
+	// Transport sends the allowed requests. Defaults to http.DefaultTransport.
+	Transport http.RoundTripper
+	// Allow selects the requests still sent, e.g. to a local server. nil
+	// blocks all of them.
+	Allow func(*http.Request) bool
+}
+
+// RoundTrip implements http.RoundTripper.
+func (o *Offline) RoundTrip(req *http.Request) (*http.Response, error) {
+	if o.Allow != nil && o.Allow(req) {
+		return transportOr(o.Transport).RoundTrip(req)
+	}
+	closeBody(req)
+	return nil, &OfflineError{Method: req.Method, URL: redactURL(req.URL)}
+}
+
+// OfflineError is returned by Offline for blocked requests.
+type OfflineError struct {
+	// Method and URL identify the request, with secrets in the URL redacted.
+	Method string
+	URL    string
+}
+
+func (o *OfflineError) Error() string {
+	return fmt.Sprintf("offline: %s %s was not sent", o.Method, o.URL)
+}
@@ -0,0 +1,35 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOffline(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+	o := &Offline{Allow: func(r *http.Request) bool { return "http://"+r.URL.Host == ts.URL }}
+	c := Client{Client: &http.Client{Transport: o}}
+	if err := c.Get(context.Background(), ts.URL, nil, &struct{}{}); err != nil {
+		t.Fatal(err)
+	}
+	err := c.Post(context.Background(), "https://example.com/x?token=abc", nil, map[string]string{}, &struct{}{})
+	var oerr *OfflineError
+	if !errors.As(err, &oerr) {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if want := "offline: POST https://example.com/x?token=REDACTED was not sent"; oerr.Error() != want {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", want, oerr.Error())
+	}
+}
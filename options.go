@@ -0,0 +1,133 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/maruel/httpjson/retry"
+)
+
+// Option configures a Client created by New.
+type Option func(c *Client) error
+
+// New returns a Client configured with opts, applied in order.
+//
+// It is an alternative to setting the Client fields one by one. The returned
+// Client is not immutable: its fields stay exported and can still be set
+// before the first request. They must not be modified once the Client is in
+// use, since they are read without synchronization.
+func New(opts ...Option) (*Client, error) {
+	c := &Client{}
+	for _, o := range opts {
+		if err := o(c); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// WithHTTPClient sets the http.Client used to send requests.
+func WithHTTPClient(h *http.Client) Option {
+	return func(c *Client) error {
+		c.Client = h
+		return nil
+	}
+}
+
+// WithTransport wraps the current transport, http.DefaultTransport by
+// default. Use it multiple times to stack transports; the last one added is
+// the outermost.
+func WithTransport(wrap func(http.RoundTripper) http.RoundTripper) Option {
+	return func(c *Client) error {
+		h := &http.Client{}
+		if c.Client != nil {
+			*h = *c.Client
+		}
+		t := h.Transport
+		if t == nil {
+			t = http.DefaultTransport
+		}
+		h.Transport = wrap(t)
+		c.Client = h
+		return nil
+	}
+}
+
+// WithBaseURL resolves the relative request URLs against base.
+func WithBaseURL(base string) Option {
+	return func(c *Client) error {
+		u, err := url.Parse(base)
+		if err != nil {
+			return err
+		}
+		if !u.IsAbs() {
+			return fmt.Errorf("base url %q is not absolute", base)
+		}
+		c.OnRequest = append(c.OnRequest, func(req *http.Request) error {
+			if !req.URL.IsAbs() {
+				req.URL = u.ResolveReference(req.URL)
+				req.Host = ""
+			}
+			return nil
+		})
+		return nil
+	}
+}
+
+// WithHeader sets a header on every request that doesn't already have it.
+func WithHeader(key, value string) Option {
+	return func(c *Client) error {
+		c.OnRequest = append(c.OnRequest, func(req *http.Request) error {
+			if req.Header.Get(key) == "" {
+				req.Header.Set(key, value)
+			}
+			return nil
+		})
+		return nil
+	}
+}
+
+// WithLenient sets Client.Lenient.
+func WithLenient() Option {
+	return func(c *Client) error {
+		c.Lenient = true
+		return nil
+	}
+}
+
+// WithRetryOn429 sets Client.RetryOn429.
+func WithRetryOn429(n int) Option {
+	return func(c *Client) error {
+		c.RetryOn429 = n
+		return nil
+	}
+}
+
+// WithRetry sets Client.Retry.
+func WithRetry(p retry.Policy) Option {
+	return func(c *Client) error {
+		c.Retry = p
+		return nil
+	}
+}
+
+// WithMaxConcurrent sets Client.MaxConcurrent.
+func WithMaxConcurrent(n int) Option {
+	return func(c *Client) error {
+		c.MaxConcurrent = n
+		return nil
+	}
+}
+
+// WithMaxResponseSize sets Client.MaxResponseSize.
+func WithMaxResponseSize(n int64) Option {
+	return func(c *Client) error {
+		c.MaxResponseSize = n
+		return nil
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_, _ = w.Write([]byte(`{"path":"` + r.URL.Path + `","agent":"` + r.Header.Get("User-Agent") + `","layers":"` + r.Header.Get("X-Layers") + `","extra":1}`))
+	}))
+	defer ts.Close()
+	layer := func(name string) func(http.RoundTripper) http.RoundTripper {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				req.Header.Add("X-Layers", name)
+				return next.RoundTrip(req)
+			})
+		}
+	}
+	c, err := New(
+		WithBaseURL(ts.URL+"/v1/"),
+		WithHeader("User-Agent", "test"),
+		WithTransport(layer("inner")),
+		WithTransport(layer("outer")),
+		WithLenient(),
+		WithMaxConcurrent(2),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out struct {
+		Path   string `json:"path"`
+		Agent  string `json:"agent"`
+		Layers string `json:"layers"`
+	}
+	if err := c.Get(context.Background(), "items", nil, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Path != "/v1/items" || out.Agent != "test" || out.Layers != "outer" {
+		t.Errorf("Unexpected %+v", out)
+	}
+	if c.MaxConcurrent != 2 {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", 2, c.MaxConcurrent)
+	}
+	if _, err := New(WithBaseURL("/relative")); err == nil {
+		t.Error("expected error")
+	}
+}
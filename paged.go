@@ -0,0 +1,49 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"reflect"
+)
+
+// GetPaged fetches pages until exhausted, starting with u.
+//
+// Each page is decoded into pageOut, which must be a pointer and is reset to
+// its zero value before each page. next is then called to consume the page and
+// return the URL of the next one, or false to stop. A relative URL is resolved
+// against the current page's URL, so both next links and cursors appended to
+// the query work.
+func (c *Client) GetPaged(ctx context.Context, u string, hdr http.Header, pageOut any, next func(pageOut any) (string, bool)) error {
+	v := reflect.ValueOf(pageOut)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return errors.New("pageOut must be a non-nil pointer")
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		v.Elem().SetZero()
+		if err := c.Get(ctx, u, hdr, pageOut); err != nil {
+			return err
+		}
+		n, ok := next(pageOut)
+		if !ok {
+			return nil
+		}
+		base, err := url.Parse(u)
+		if err != nil {
+			return err
+		}
+		ref, err := url.Parse(n)
+		if err != nil {
+			return err
+		}
+		u = base.ResolveReference(ref).String()
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestClient_GetPaged(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		switch r.URL.Query().Get("cursor") {
+		case "":
+			_, _ = w.Write([]byte(`{"items":[1,2],"next":"/items?cursor=b"}`))
+		case "b":
+			_, _ = w.Write([]byte(`{"items":[3],"next":"?cursor=c"}`))
+		default:
+			// The last page has no next link.
+			_, _ = w.Write([]byte(`{"items":[4]}`))
+		}
+	}))
+	defer ts.Close()
+
+	type page struct {
+		Items []int  `json:"items"`
+		Next  string `json:"next"`
+	}
+	var got []int
+	var p page
+	err := (&Client{}).GetPaged(context.Background(), ts.URL+"/items", nil, &p, func(out any) (string, bool) {
+		pg := out.(*page)
+		got = append(got, pg.Items...)
+		return pg.Next, pg.Next != ""
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{1, 2, 3, 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", want, got)
+	}
+}
+
+func TestClient_GetPaged_error(t *testing.T) {
+	next := func(any) (string, bool) { return "", false }
+	if err := (&Client{}).GetPaged(context.Background(), "http://localhost", nil, map[string]any{}, next); err == nil {
+		t.Error("expected error")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := (&Client{}).GetPaged(ctx, "http://localhost", nil, &map[string]any{}, next); err != context.Canceled {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", context.Canceled, err)
+	}
+}
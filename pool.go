@@ -0,0 +1,34 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"bytes"
+	"sync"
+)
+
+// maxPooledBuffer is the largest buffer kept in bufPool, so a single huge
+// body doesn't stay pinned in memory.
+const maxPooledBuffer = 1 << 20
+
+// bufPool holds the scratch buffers used to encode requests and read
+// responses. The result is copied out to an exactly sized slice, so the
+// growth allocations are amortized across calls.
+var bufPool = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+func getBuffer() *bytes.Buffer {
+	return bufPool.Get().(*bytes.Buffer)
+}
+
+// putBuffer returns b to bufPool. It reports whether b was pooled; oversized
+// buffers are left for the garbage collector.
+func putBuffer(b *bytes.Buffer) bool {
+	if b.Cap() > maxPooledBuffer {
+		return false
+	}
+	b.Reset()
+	bufPool.Put(b)
+	return true
+}
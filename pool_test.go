@@ -0,0 +1,97 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestPutBuffer(t *testing.T) {
+	t.Parallel()
+	big := new(bytes.Buffer)
+	big.Grow(maxPooledBuffer + 1)
+	big.WriteString("a")
+	if putBuffer(big) {
+		t.Error("oversized buffer was pooled")
+	}
+	if big.Len() != 1 {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", 1, big.Len())
+	}
+	small := new(bytes.Buffer)
+	small.WriteString("a")
+	if !putBuffer(small) {
+		t.Error("buffer was not pooled")
+	}
+	if small.Len() != 0 {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", 0, small.Len())
+	}
+}
+
+// benchBody is a ~100KiB JSON body.
+var benchBody = []byte(`{"items":[` + strings.Repeat(`{"name":"aaaaaaaaaaaaaaaaaaaaaaaa","value":123456789},`, 2000) + `{}]}`)
+
+func newBenchResponse(chunked bool) *http.Response {
+	resp := &http.Response{
+		Body:          io.NopCloser(bytes.NewReader(benchBody)),
+		ContentLength: int64(len(benchBody)),
+	}
+	if chunked {
+		resp.ContentLength = -1
+	}
+	return resp
+}
+
+func BenchmarkReadBody(b *testing.B) {
+	for _, chunked := range []bool{false, true} {
+		name := "content_length"
+		if chunked {
+			name = "chunked"
+		}
+		b.Run(name, func(b *testing.B) {
+			b.Run("ReadAll", func(b *testing.B) {
+				b.ReportAllocs()
+				b.SetBytes(int64(len(benchBody)))
+				for b.Loop() {
+					if _, err := io.ReadAll(newBenchResponse(chunked).Body); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+			b.Run("pooled", func(b *testing.B) {
+				b.ReportAllocs()
+				b.SetBytes(int64(len(benchBody)))
+				for b.Loop() {
+					if _, err := readBody(newBenchResponse(chunked)); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkMarshal(b *testing.B) {
+	type Item struct {
+		Name  string `json:"name"`
+		Value int    `json:"value"`
+	}
+	in := struct {
+		Items []Item `json:"items"`
+	}{Items: make([]Item, 2000)}
+	for i := range in.Items {
+		in.Items[i] = Item{Name: strings.Repeat("a", 24), Value: 123456789}
+	}
+	b.ReportAllocs()
+	var c Client
+	for b.Loop() {
+		if _, err := c.marshal(in); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+)
+
+type priorityKey struct{}
+
+// WithPriority returns a context for requests with the given priority.
+//
+// When Client.MaxConcurrent is saturated, waiting requests with a higher
+// priority are sent first. Requests with the same priority are sent in the
+// order they started waiting. The default priority is 0; use a negative
+// value for bulk traffic that should yield to interactive requests.
+func WithPriority(ctx context.Context, priority int) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+func priorityFrom(ctx context.Context) int {
+	p, _ := ctx.Value(priorityKey{}).(int)
+	return p
+}
+
+// semaphore limits concurrency, granting released slots to the waiter with
+// the highest priority.
+type semaphore struct {
+	mu      sync.Mutex
+	size    int
+	used    int
+	seq     uint64
+	waiters waitQueue
+}
+
+func (s *semaphore) acquire(ctx context.Context, priority int) error {
+	s.mu.Lock()
+	if s.used < s.size && len(s.waiters) == 0 {
+		s.used++
+		s.mu.Unlock()
+		return nil
+	}
+	s.seq++
+	w := &waiter{priority: priority, seq: s.seq, ready: make(chan struct{})}
+	heap.Push(&s.waiters, w)
+	s.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		if w.index < 0 {
+			// The slot was granted concurrently; hand it over.
+			s.mu.Unlock()
+			s.release()
+		} else {
+			heap.Remove(&s.waiters, w.index)
+			s.mu.Unlock()
+		}
+		return ctx.Err()
+	}
+}
+
+func (s *semaphore) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.waiters) == 0 {
+		s.used--
+		return
+	}
+	w := heap.Pop(&s.waiters).(*waiter)
+	close(w.ready)
+}
+
+type waiter struct {
+	priority int
+	seq      uint64
+	ready    chan struct{}
+	// index is the position in waitQueue, -1 once granted.
+	index int
+}
+
+// waitQueue implements heap.Interface.
+type waitQueue []*waiter
+
+func (q waitQueue) Len() int { return len(q) }
+
+func (q waitQueue) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority > q[j].priority
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q waitQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *waitQueue) Push(x any) {
+	w := x.(*waiter)
+	w.index = len(*q)
+	*q = append(*q, w)
+}
+
+func (q *waitQueue) Pop() any {
+	old := *q
+	w := old[len(old)-1]
+	old[len(old)-1] = nil
+	w.index = -1
+	*q = old[:len(old)-1]
+	return w
+}
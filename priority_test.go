@@ -0,0 +1,79 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"context"
+	"runtime"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestSemaphore_priority(t *testing.T) {
+	t.Parallel()
+	s := &semaphore{size: 1}
+	ctx := context.Background()
+	if err := s.acquire(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var order []int
+	var wg sync.WaitGroup
+	for i, p := range []int{-1, 0, 1, 0} {
+		wg.Go(func() {
+			if err := s.acquire(ctx, priorityFrom(WithPriority(ctx, p))); err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+			s.release()
+		})
+		// Wait for the goroutine to be queued so the sequence is deterministic.
+		for {
+			s.mu.Lock()
+			l := len(s.waiters)
+			s.mu.Unlock()
+			if l == i+1 {
+				break
+			}
+			runtime.Gosched()
+		}
+	}
+	s.release()
+	wg.Wait()
+	if want := []int{2, 1, 3, 0}; !slices.Equal(order, want) {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", want, order)
+	}
+	if s.used != 0 {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", 0, s.used)
+	}
+}
+
+func TestSemaphore_cancel(t *testing.T) {
+	t.Parallel()
+	s := &semaphore{size: 1}
+	if err := s.acquire(context.Background(), 0); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := s.acquire(ctx, 0); err != context.Canceled {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", context.Canceled, err)
+	}
+	if l := len(s.waiters); l != 0 {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", 0, l)
+	}
+	s.release()
+	if err := s.acquire(context.Background(), 0); err != nil {
+		t.Fatal(err)
+	}
+	s.release()
+	if s.used != 0 {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", 0, s.used)
+	}
+}
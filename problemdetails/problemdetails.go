@@ -0,0 +1,117 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package problemdetails writes RFC 9457 problem details responses.
+//
+// Services built alongside httpjson clients can use it to emit errors that
+// httpjson.Error.API() decodes.
+//
+// See https://www.rfc-editor.org/rfc/rfc9457.
+package problemdetails
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+)
+
+// ContentType is the media type of problem details.
+const ContentType = "application/problem+json"
+
+// ProblemDetails is an RFC 9457 problem details object.
+type ProblemDetails struct {
+	// Type is a URI identifying the problem type. Empty means "about:blank",
+	// i.e. the problem is described by the status code alone.
+	Type string `json:"type,omitempty"`
+	// Title is a short summary of the problem type. It defaults to the status
+	// text.
+	Title string `json:"title,omitempty"`
+	// Status is the HTTP status code. Write sets it.
+	Status int `json:"status,omitempty"`
+	// Detail is the explanation specific to this occurrence of the problem.
+	Detail string `json:"detail,omitempty"`
+	// Instance is a URI identifying this occurrence of the problem.
+	Instance string `json:"instance,omitempty"`
+	// Extensions are additional members. They can't override the members
+	// above.
+	Extensions map[string]any `json:"-"`
+}
+
+// MarshalJSON implements json.Marshaler, inlining the extension members.
+func (p ProblemDetails) MarshalJSON() ([]byte, error) {
+	type alias ProblemDetails
+	if len(p.Extensions) == 0 {
+		return json.Marshal(alias(p))
+	}
+	b, err := json.Marshal(alias(p))
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]any
+	if err = json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	out := maps.Clone(p.Extensions)
+	maps.Copy(out, m)
+	return json.Marshal(out)
+}
+
+// Write writes p as the response with the status code.
+//
+// p.Status is set to status and p.Title defaults to the status text.
+func Write(w http.ResponseWriter, status int, p ProblemDetails) error {
+	p.Status = status
+	if p.Title == "" && p.Type == "" {
+		p.Title = http.StatusText(status)
+	}
+	b, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(status)
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// New returns a problem for the status code with detail.
+func New(status int, detail string) ProblemDetails {
+	return ProblemDetails{Title: http.StatusText(status), Status: status, Detail: detail}
+}
+
+// BadRequest returns a 400 problem.
+func BadRequest(detail string) ProblemDetails {
+	return New(http.StatusBadRequest, detail)
+}
+
+// Unauthorized returns a 401 problem.
+func Unauthorized(detail string) ProblemDetails {
+	return New(http.StatusUnauthorized, detail)
+}
+
+// Forbidden returns a 403 problem.
+func Forbidden(detail string) ProblemDetails {
+	return New(http.StatusForbidden, detail)
+}
+
+// NotFound returns a 404 problem.
+func NotFound(detail string) ProblemDetails {
+	return New(http.StatusNotFound, detail)
+}
+
+// Conflict returns a 409 problem.
+func Conflict(detail string) ProblemDetails {
+	return New(http.StatusConflict, detail)
+}
+
+// TooManyRequests returns a 429 problem.
+func TooManyRequests(detail string) ProblemDetails {
+	return New(http.StatusTooManyRequests, detail)
+}
+
+// Internal returns a 500 problem. Don't leak internal error messages in
+// detail.
+func Internal(detail string) ProblemDetails {
+	return New(http.StatusInternalServerError, detail)
+}
@@ -0,0 +1,89 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package problemdetails
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/maruel/httpjson"
+)
+
+func TestWrite(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := NotFound("no item 42")
+		p.Type = "https://example.com/probs/missing"
+		p.Extensions = map[string]any{"item": 42, "status": "ignored"}
+		if err := Write(w, http.StatusNotFound, p); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer ts.Close()
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != ContentType {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", ContentType, ct)
+	}
+	var got map[string]any
+	if err = json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"type":   "https://example.com/probs/missing",
+		"title":  "Not Found",
+		"status": 404.,
+		"detail": "no item 42",
+		"item":   42.,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Unexpected\nwant: %v\ngot:  %v", want, got)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s: Unexpected\nwant: %v\ngot:  %v", k, v, got[k])
+		}
+	}
+}
+
+func TestWrite_client(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = Write(w, http.StatusConflict, Conflict("version mismatch"))
+	}))
+	defer ts.Close()
+	var out struct{}
+	err := (&httpjson.Client{}).Get(context.Background(), ts.URL, nil, &out)
+	var herr *httpjson.Error
+	if !errors.As(err, &herr) || herr.StatusCode != http.StatusConflict {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	api := herr.API()
+	if api == nil || api.Message != "version mismatch" {
+		t.Fatalf("Unexpected %+v", api)
+	}
+}
+
+func TestProblemDetails_MarshalJSON(t *testing.T) {
+	t.Parallel()
+	p := NotFound("no item 42")
+	p.Extensions = map[string]any{"item": 42}
+	// Marshaling the value, not a pointer, keeps the extensions.
+	b, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"detail":"no item 42","item":42,"status":404,"title":"Not Found"}`
+	if got := string(b); got != want {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", want, got)
+	}
+}
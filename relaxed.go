@@ -0,0 +1,87 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+// relaxJSON removes // and /* */ comments and trailing commas in objects and
+// arrays from b. Strings are left untouched. Invalid input is left for the
+// JSON decoder to report.
+func relaxJSON(b []byte) []byte {
+	return stripTrailingCommas(stripComments(b))
+}
+
+func stripComments(b []byte) []byte {
+	out := make([]byte, 0, len(b))
+	inString := false
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		if inString {
+			out = append(out, c)
+			if c == '\\' && i+1 < len(b) {
+				i++
+				out = append(out, b[i])
+			} else if c == '"' {
+				inString = false
+			}
+			continue
+		}
+		if c == '/' && i+1 < len(b) {
+			switch b[i+1] {
+			case '/':
+				for i < len(b) && b[i] != '\n' {
+					i++
+				}
+				// Keep the new line, if any.
+				i--
+				continue
+			case '*':
+				i += 2
+				for i+1 < len(b) && (b[i] != '*' || b[i+1] != '/') {
+					i++
+				}
+				i++
+				// Keep tokens separated.
+				out = append(out, ' ')
+				continue
+			}
+		}
+		if c == '"' {
+			inString = true
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+func stripTrailingCommas(b []byte) []byte {
+	out := make([]byte, 0, len(b))
+	inString := false
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		if inString {
+			out = append(out, c)
+			if c == '\\' && i+1 < len(b) {
+				i++
+				out = append(out, b[i])
+			} else if c == '"' {
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case ',':
+			j := i + 1
+			for j < len(b) && (b[j] == ' ' || b[j] == '\t' || b[j] == '\n' || b[j] == '\r') {
+				j++
+			}
+			if j < len(b) && (b[j] == '}' || b[j] == ']') {
+				continue
+			}
+		}
+		out = append(out, c)
+	}
+	return out
+}
@@ -0,0 +1,49 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRelaxJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"valid", `{"a":[1,2]}`, `{"a":[1,2]}`},
+		{"line comment", "{\"a\":1 // one\n}", "{\"a\":1 \n}"},
+		{"block comment", `{/* c */"a":1}`, `{ "a":1}`},
+		{"unterminated comment", `{"a":1} /* c`, `{"a":1}  `},
+		{"trailing commas", "{\"a\":[1,2,],\n}", "{\"a\":[1,2]\n}"},
+		{"comment before close", "[1, // last\n]", "[1 \n]"},
+		{"strings", `{"a":"// not, a /* comment */,]","b":"\",]"}`, `{"a":"// not, a /* comment */,]","b":"\",]"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(relaxJSON([]byte(tt.in))); got != tt.want {
+				t.Errorf("Unexpected\nwant: %q\ngot:  %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestClient_decode_relaxed(t *testing.T) {
+	var out struct {
+		A []int `json:"a"`
+	}
+	data := []byte("{\n  // Comment.\n  \"a\": [1, 2,],\n}")
+	if err := (&Client{}).decode(context.Background(), data, &out); err == nil {
+		t.Fatal("expected error")
+	}
+	if err := (&Client{Relaxed: true}).decode(context.Background(), data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.A) != 2 {
+		t.Errorf("Unexpected %v", out.A)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package retry
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Budget limits the retries to a fraction of the requests, across all the
+// requests sharing it, so a systemic outage doesn't multiply the traffic by
+// the number of retries per request.
+//
+// It is a token bucket: each request deposits ratio tokens, up to burst, and
+// each retry withdraws one. It is safe for concurrent use.
+type Budget struct {
+	ratio float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+}
+
+// NewBudget returns a Budget allowing retries for ratio of the requests, e.g.
+// 0.1 for one retry every ten requests, and up to burst retries in a row. It
+// starts full.
+func NewBudget(ratio float64, burst int) *Budget {
+	return &Budget{ratio: ratio, burst: float64(burst), tokens: float64(burst)}
+}
+
+// Deposit records a request, excluding retries.
+func (b *Budget) Deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = min(b.tokens+b.ratio, b.burst)
+}
+
+// Withdraw takes one retry from the budget and reports whether there was
+// one left.
+func (b *Budget) Withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// BudgetError is returned instead of retrying a request when the Budget is
+// exhausted.
+type BudgetError struct {
+	// Attempts is the number of times the request was sent.
+	Attempts int
+	// StatusCode is the status of the last response, 0 if the last attempt
+	// failed without a response.
+	StatusCode int
+	// Err is the error of the last attempt, if any. httpjson.Client sets it to
+	// the *httpjson.Error of the last response when its status code is 400 or
+	// higher, so the response body remains available.
+	Err error
+}
+
+func (e *BudgetError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("retry budget exhausted after %d attempts: %v", e.Attempts, e.Err)
+	}
+	return fmt.Sprintf("retry budget exhausted after %d attempts: http %d", e.Attempts, e.StatusCode)
+}
+
+func (e *BudgetError) Unwrap() error {
+	return e.Err
+}
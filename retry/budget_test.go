@@ -0,0 +1,58 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package retry
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestBudget(t *testing.T) {
+	t.Parallel()
+	b := NewBudget(0.5, 2)
+	// Starts full.
+	if !b.Withdraw() || !b.Withdraw() {
+		t.Fatal("expected the initial burst")
+	}
+	if b.Withdraw() {
+		t.Fatal("expected exhaustion")
+	}
+	// Two requests pay for one retry.
+	b.Deposit()
+	if b.Withdraw() {
+		t.Fatal("expected exhaustion")
+	}
+	b.Deposit()
+	if !b.Withdraw() {
+		t.Fatal("expected a retry")
+	}
+	// Capped at burst.
+	for range 10 {
+		b.Deposit()
+	}
+	n := 0
+	for b.Withdraw() {
+		n++
+	}
+	if n != 2 {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", 2, n)
+	}
+}
+
+func TestBudgetError(t *testing.T) {
+	t.Parallel()
+	err := &BudgetError{Attempts: 2, Err: io.ErrUnexpectedEOF}
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Error("expected to unwrap")
+	}
+	if want := "retry budget exhausted after 2 attempts: unexpected EOF"; err.Error() != want {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", want, err.Error())
+	}
+	err = &BudgetError{Attempts: 1, StatusCode: 503}
+	if want := "retry budget exhausted after 1 attempts: http 503"; err.Error() != want {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", want, err.Error())
+	}
+}
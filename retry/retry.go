@@ -0,0 +1,298 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package retry implements composable retry policies for HTTP requests.
+//
+// A Policy is built from small pieces: classifiers deciding what is retried
+// (Status, ServerErrors, TransportErrors, RetryAfter) with a Backoff
+// deciding how long to wait, combined with Any and bounded with MaxRetries,
+// Deadline, Idempotent and Methods. A Budget bounds the retries across requests.
+//
+// The policy is used by httpjson.Client.Retry and can be used by any
+// transport retrying requests.
+package retry
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Policy decides whether req is retried after attempt, the 1-based number of
+// the attempt that just completed, and how long to wait first.
+//
+// Exactly one of resp and err is usually set. The policy must not read or
+// close resp.Body.
+type Policy func(req *http.Request, attempt int, resp *http.Response, err error) (time.Duration, bool)
+
+// Backoff decides how long to wait before the retry following attempt,
+// given the response or error of that attempt. It returns false to stop
+// retrying.
+//
+// Implement it to swap the waiting strategy of a Policy without rewriting the
+// policy.
+type Backoff interface {
+	NextDelay(attempt int, resp *http.Response, err error) (time.Duration, bool)
+}
+
+// BackoffFunc adapts a function to Backoff.
+type BackoffFunc func(attempt int, resp *http.Response, err error) (time.Duration, bool)
+
+// NextDelay implements Backoff.
+func (f BackoffFunc) NextDelay(attempt int, resp *http.Response, err error) (time.Duration, bool) {
+	return f(attempt, resp, err)
+}
+
+// Constant always waits d.
+func Constant(d time.Duration) Backoff {
+	return BackoffFunc(func(int, *http.Response, error) (time.Duration, bool) {
+		return d, true
+	})
+}
+
+// Exponential waits base, then twice as long after each attempt, up to limit.
+// Wrap it with Jitter for exponential backoff with jitter.
+func Exponential(base, limit time.Duration) Backoff {
+	return BackoffFunc(func(attempt int, _ *http.Response, _ error) (time.Duration, bool) {
+		d := base
+		for i := 1; i < attempt && d < limit; i++ {
+			d *= 2
+		}
+		return min(d, limit), true
+	})
+}
+
+// Jitter randomizes the delay of b between half and all of it, so clients
+// don't retry in lockstep.
+func Jitter(b Backoff) Backoff {
+	return BackoffFunc(func(attempt int, resp *http.Response, err error) (time.Duration, bool) {
+		d, ok := b.NextDelay(attempt, resp, err)
+		if !ok || d <= 1 {
+			return d, ok
+		}
+		return d/2 + rand.N(d/2), true
+	})
+}
+
+// RetryAfterOr waits as the response's Retry-After header says. Without a
+// valid header, it waits as fallback says, or stops retrying if fallback is
+// nil.
+func RetryAfterOr(fallback Backoff) Backoff {
+	return BackoffFunc(func(attempt int, resp *http.Response, err error) (time.Duration, bool) {
+		if resp != nil {
+			if d, ok := ParseRetryAfter(resp.Header.Get("Retry-After")); ok {
+				return d, true
+			}
+		}
+		if fallback == nil {
+			return 0, false
+		}
+		return fallback.NextDelay(attempt, resp, err)
+	})
+}
+
+// Status retries responses with one of codes, waiting as b says.
+func Status(b Backoff, codes ...int) Policy {
+	return func(req *http.Request, attempt int, resp *http.Response, err error) (time.Duration, bool) {
+		if resp == nil || !slices.Contains(codes, resp.StatusCode) {
+			return 0, false
+		}
+		return b.NextDelay(attempt, resp, err)
+	}
+}
+
+// ServerErrors retries 502, 503 and 504 responses, waiting as b says.
+//
+// Other 5xx are not retried since they usually mean the request can't
+// succeed.
+func ServerErrors(b Backoff) Policy {
+	return Status(b, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout)
+}
+
+// TransportErrors retries requests that failed without a response because
+// of a transient network error, see IsTransient, waiting as b says.
+func TransportErrors(b Backoff) Policy {
+	return func(req *http.Request, attempt int, resp *http.Response, err error) (time.Duration, bool) {
+		if resp != nil || !IsTransient(err) || req.Context().Err() != nil {
+			return 0, false
+		}
+		return b.NextDelay(attempt, resp, err)
+	}
+}
+
+// IsTransient reports whether err is a network failure that may not happen
+// again: a timeout, a connection refused, reset or closed early, or a
+// temporary DNS failure.
+//
+// Permanent failures are not transient even though they happen at the same
+// level, like a TLS certificate verification error, an unsupported URL
+// scheme or a destination refused by the dialer. Context cancellation is
+// never transient.
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var cerr *tls.CertificateVerificationError
+	var uaerr x509.UnknownAuthorityError
+	var herr x509.HostnameError
+	var ierr x509.CertificateInvalidError
+	if errors.As(err, &cerr) || errors.As(err, &uaerr) || errors.As(err, &herr) || errors.As(err, &ierr) {
+		return false
+	}
+	var derr *net.DNSError
+	if errors.As(err, &derr) {
+		return derr.IsTimeout || derr.IsTemporary
+	}
+	var nerr net.Error
+	if errors.As(err, &nerr) && nerr.Timeout() {
+		return true
+	}
+	for _, e := range transientErrors {
+		if errors.Is(err, e) {
+			return true
+		}
+	}
+	// The server closed a kept alive connection before replying.
+	var uerr *url.Error
+	return errors.As(err, &uerr) && errors.Is(uerr.Err, io.EOF)
+}
+
+var transientErrors = []error{
+	syscall.ECONNREFUSED,
+	syscall.ECONNRESET,
+	syscall.ECONNABORTED,
+	syscall.EPIPE,
+	syscall.ETIMEDOUT,
+	syscall.EHOSTUNREACH,
+	syscall.ENETUNREACH,
+	io.ErrUnexpectedEOF,
+}
+
+// RetryAfter retries responses with one of codes and a valid Retry-After
+// header, waiting as instructed by the server.
+func RetryAfter(codes ...int) Policy {
+	return func(req *http.Request, attempt int, resp *http.Response, err error) (time.Duration, bool) {
+		if resp == nil || !slices.Contains(codes, resp.StatusCode) {
+			return 0, false
+		}
+		return ParseRetryAfter(resp.Header.Get("Retry-After"))
+	}
+}
+
+// Idempotent stops p for requests that are not safe to send twice: only
+// GET, HEAD, OPTIONS and TRACE requests and requests with an Idempotency-Key
+// or X-Idempotency-Key header are retried. This is the rule net/http applies
+// to retry requests on a broken connection.
+func Idempotent(p Policy) Policy {
+	return Methods(p, idempotentMethods...)
+}
+
+// IsIdempotent reports whether req can be sent twice without side effects,
+// see Idempotent.
+func IsIdempotent(req *http.Request) bool {
+	return hasMethod(req, idempotentMethods)
+}
+
+// Methods stops p for requests whose method is not one of methods, unless
+// they have an Idempotency-Key or X-Idempotency-Key header. Use it for APIs
+// whose retry contract differs from Idempotent, e.g. to also retry PUT and
+// DELETE.
+func Methods(p Policy, methods ...string) Policy {
+	methods = slices.Clone(methods)
+	return func(req *http.Request, attempt int, resp *http.Response, err error) (time.Duration, bool) {
+		if !hasMethod(req, methods) {
+			return 0, false
+		}
+		return p(req, attempt, resp, err)
+	}
+}
+
+var idempotentMethods = []string{http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace}
+
+func hasMethod(req *http.Request, methods []string) bool {
+	m := req.Method
+	if m == "" {
+		m = http.MethodGet
+	}
+	if slices.Contains(methods, m) {
+		return true
+	}
+	_, ok := req.Header["Idempotency-Key"]
+	if !ok {
+		_, ok = req.Header["X-Idempotency-Key"]
+	}
+	return ok
+}
+
+// Any returns the decision of the first of policies that retries.
+func Any(policies ...Policy) Policy {
+	return func(req *http.Request, attempt int, resp *http.Response, err error) (time.Duration, bool) {
+		for _, p := range policies {
+			if d, ok := p(req, attempt, resp, err); ok {
+				return d, true
+			}
+		}
+		return 0, false
+	}
+}
+
+// MaxRetries stops p after n retries.
+func MaxRetries(n int, p Policy) Policy {
+	return func(req *http.Request, attempt int, resp *http.Response, err error) (time.Duration, bool) {
+		if attempt > n {
+			return 0, false
+		}
+		return p(req, attempt, resp, err)
+	}
+}
+
+// Deadline stops p when waiting would exceed the context deadline, so the
+// last response or error is returned instead of a context error.
+func Deadline(p Policy) Policy {
+	return func(req *http.Request, attempt int, resp *http.Response, err error) (time.Duration, bool) {
+		d, ok := p(req, attempt, resp, err)
+		if !ok {
+			return 0, false
+		}
+		if deadline, ok := req.Context().Deadline(); ok && time.Now().Add(d).After(deadline) {
+			return 0, false
+		}
+		return d, true
+	}
+}
+
+// maxRetryAfter is the largest Retry-After in seconds that fits in a
+// time.Duration.
+const maxRetryAfter = int64(math.MaxInt64 / time.Second)
+
+// ParseRetryAfter parses a Retry-After header, either in seconds or as an
+// HTTP date.
+func ParseRetryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if s, err := strconv.ParseInt(v, 10, 64); err == nil {
+		if s < 0 {
+			return 0, false
+		}
+		// Clamp to avoid overflowing time.Duration.
+		return time.Duration(min(s, maxRetryAfter)) * time.Second, true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	return max(time.Until(t), 0), true
+}
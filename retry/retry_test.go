@@ -0,0 +1,252 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package retry
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestPolicy(t *testing.T) {
+	t.Parallel()
+	req := httptest.NewRequest(http.MethodGet, "http://x", nil)
+	resp := func(code int, retryAfter string) *http.Response {
+		r := &http.Response{StatusCode: code, Header: http.Header{}}
+		if retryAfter != "" {
+			r.Header.Set("Retry-After", retryAfter)
+		}
+		return r
+	}
+	transportErr := &url.Error{Op: "Get", URL: "http://x", Err: syscall.ECONNRESET}
+	p := MaxRetries(3, Any(
+		RetryAfter(http.StatusTooManyRequests, http.StatusServiceUnavailable),
+		ServerErrors(Exponential(time.Second, 5*time.Second)),
+		TransportErrors(Constant(time.Millisecond)),
+	))
+	data := []struct {
+		name    string
+		attempt int
+		resp    *http.Response
+		err     error
+		want    time.Duration
+		ok      bool
+	}{
+		{"ok", 1, resp(200, ""), nil, 0, false},
+		{"retry_after", 1, resp(429, "7"), nil, 7 * time.Second, true},
+		{"429_without_header", 1, resp(429, ""), nil, 0, false},
+		{"503_retry_after", 2, resp(503, "1"), nil, time.Second, true},
+		{"503_backoff", 2, resp(503, ""), nil, 2 * time.Second, true},
+		{"503_backoff_max", 3, resp(503, ""), nil, 4 * time.Second, true},
+		{"500", 1, resp(500, ""), nil, 0, false},
+		{"transport", 1, nil, transportErr, time.Millisecond, true},
+		{"other_error", 1, nil, errors.New("bad"), 0, false},
+		{"max_retries", 4, resp(503, ""), nil, 0, false},
+	}
+	for _, line := range data {
+		t.Run(line.name, func(t *testing.T) {
+			d, ok := p(req, line.attempt, line.resp, line.err)
+			if d != line.want || ok != line.ok {
+				t.Errorf("Unexpected\nwant: %s %t\ngot:  %s %t", line.want, line.ok, d, ok)
+			}
+		})
+	}
+}
+
+func TestDeadline(t *testing.T) {
+	t.Parallel()
+	p := Deadline(Status(Constant(time.Hour), http.StatusBadGateway))
+	r := &http.Response{StatusCode: http.StatusBadGateway}
+	req := httptest.NewRequest(http.MethodGet, "http://x", nil)
+	if _, ok := p(req, 1, r, nil); !ok {
+		t.Error("expected retry without deadline")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if _, ok := p(req.WithContext(ctx), 1, r, nil); ok {
+		t.Error("expected no retry past the deadline")
+	}
+	// A canceled context isn't a transport error worth retrying.
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if _, ok := TransportErrors(Constant(0))(req.WithContext(ctx), 1, nil, &url.Error{Err: context.Canceled}); ok {
+		t.Error("expected no retry once canceled")
+	}
+}
+
+func TestIdempotent(t *testing.T) {
+	t.Parallel()
+	p := Idempotent(Status(Constant(0), http.StatusBadGateway))
+	r := &http.Response{StatusCode: http.StatusBadGateway}
+	data := []struct {
+		method string
+		hdr    string
+		want   bool
+	}{
+		{http.MethodGet, "", true},
+		{http.MethodHead, "", true},
+		{http.MethodOptions, "", true},
+		{http.MethodTrace, "", true},
+		{http.MethodPost, "", false},
+		{http.MethodPatch, "", false},
+		{http.MethodPut, "", false},
+		{http.MethodDelete, "", false},
+		{http.MethodPost, "Idempotency-Key", true},
+		{http.MethodPatch, "X-Idempotency-Key", true},
+	}
+	for _, line := range data {
+		req := httptest.NewRequest(line.method, "http://x", nil)
+		if line.hdr != "" {
+			req.Header.Set(line.hdr, "1")
+		}
+		if _, ok := p(req, 1, r, nil); ok != line.want {
+			t.Errorf("%s %q: Unexpected\nwant: %t\ngot:  %t", line.method, line.hdr, line.want, ok)
+		}
+	}
+}
+
+func TestMethods(t *testing.T) {
+	t.Parallel()
+	p := Methods(Status(Constant(0), http.StatusBadGateway), http.MethodPut, http.MethodDelete)
+	r := &http.Response{StatusCode: http.StatusBadGateway}
+	data := []struct {
+		method string
+		hdr    string
+		want   bool
+	}{
+		{http.MethodPut, "", true},
+		{http.MethodDelete, "", true},
+		{http.MethodGet, "", false},
+		{"", "", false},
+		{http.MethodPost, "", false},
+		{http.MethodPost, "Idempotency-Key", true},
+	}
+	for _, line := range data {
+		req := httptest.NewRequest(http.MethodGet, "http://x", nil)
+		req.Method = line.method
+		if line.hdr != "" {
+			req.Header.Set(line.hdr, "1")
+		}
+		if _, ok := p(req, 1, r, nil); ok != line.want {
+			t.Errorf("%q %q: Unexpected\nwant: %t\ngot:  %t", line.method, line.hdr, line.want, ok)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	t.Parallel()
+	e := Exponential(100*time.Millisecond, time.Second)
+	for i, want := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		if got, ok := e.NextDelay(i+1, nil, nil); !ok || got != want*time.Millisecond {
+			t.Errorf("#%d: Unexpected\nwant: %v\ngot:  %v", i, want*time.Millisecond, got)
+		}
+	}
+	j := Jitter(Constant(time.Second))
+	for range 100 {
+		if d, ok := j.NextDelay(1, nil, nil); !ok || d < 500*time.Millisecond || d >= time.Second {
+			t.Fatalf("Unexpected jitter %s", d)
+		}
+	}
+	stop := BackoffFunc(func(int, *http.Response, error) (time.Duration, bool) { return 0, false })
+	if _, ok := Jitter(stop).NextDelay(1, nil, nil); ok {
+		t.Error("Jitter must stop when the wrapped Backoff stops")
+	}
+}
+
+func TestRetryAfterOr(t *testing.T) {
+	t.Parallel()
+	withHeader := &http.Response{StatusCode: 503, Header: http.Header{"Retry-After": []string{"3"}}}
+	without := &http.Response{StatusCode: 503, Header: http.Header{}}
+	data := []struct {
+		b    Backoff
+		resp *http.Response
+		want time.Duration
+		ok   bool
+	}{
+		{RetryAfterOr(nil), withHeader, 3 * time.Second, true},
+		{RetryAfterOr(nil), without, 0, false},
+		{RetryAfterOr(nil), nil, 0, false},
+		{RetryAfterOr(Constant(time.Second)), withHeader, 3 * time.Second, true},
+		{RetryAfterOr(Constant(time.Second)), without, time.Second, true},
+	}
+	for i, line := range data {
+		if d, ok := line.b.NextDelay(1, line.resp, nil); d != line.want || ok != line.ok {
+			t.Errorf("#%d: Unexpected\nwant: %v, %t\ngot:  %v, %t", i, line.want, line.ok, d, ok)
+		}
+	}
+	// Used with a Policy.
+	p := Status(RetryAfterOr(nil), http.StatusServiceUnavailable)
+	req := httptest.NewRequest(http.MethodGet, "http://x", nil)
+	if d, ok := p(req, 1, withHeader, nil); !ok || d != 3*time.Second {
+		t.Errorf("Unexpected %v, %t", d, ok)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if d, ok := ParseRetryAfter("2"); !ok || d != 2*time.Second {
+		t.Errorf("Unexpected %s %t", d, ok)
+	}
+	if d, ok := ParseRetryAfter(time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)); !ok || d != 0 {
+		t.Errorf("Unexpected %s %t", d, ok)
+	}
+	if d, ok := ParseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)); !ok || d < 59*time.Minute {
+		t.Errorf("Unexpected %s %t", d, ok)
+	}
+	if d, ok := ParseRetryAfter("99999999999999999999"); ok {
+		t.Errorf("Unexpected %s %t", d, ok)
+	}
+	if d, ok := ParseRetryAfter("9999999999999"); !ok || d <= 0 {
+		t.Errorf("Unexpected %s %t", d, ok)
+	}
+	for _, v := range []string{"", "-1", "soon"} {
+		if _, ok := ParseRetryAfter(v); ok {
+			t.Errorf("Unexpected success for %q", v)
+		}
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	t.Parallel()
+	wrap := func(err error) error {
+		return &url.Error{Op: "Get", URL: "http://localhost", Err: err}
+	}
+	data := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"refused", wrap(&net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}), true},
+		{"reset", wrap(&net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}), true},
+		{"eof", wrap(io.EOF), true},
+		{"unexpected_eof", io.ErrUnexpectedEOF, true},
+		{"timeout", wrap(&net.DNSError{Err: "timeout", IsTimeout: true}), true},
+		{"no_such_host", wrap(&net.DNSError{Err: "no such host", IsNotFound: true}), false},
+		{"dial_refused_by_policy", wrap(&net.OpError{Op: "dial", Err: errors.New("destination refused")}), false},
+		{"x509", wrap(&tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}}), false},
+		{"hostname", wrap(x509.HostnameError{Host: "x"}), false},
+		{"scheme", wrap(errors.New(`unsupported protocol scheme "ftp"`)), false},
+		{"canceled", wrap(context.Canceled), false},
+		{"deadline", wrap(context.DeadlineExceeded), false},
+		{"other", errors.New("other"), false},
+	}
+	for _, line := range data {
+		t.Run(line.name, func(t *testing.T) {
+			if got := IsTransient(line.err); got != line.want {
+				t.Errorf("Unexpected\nwant: %t\ngot:  %t", line.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// snakeCase converts a Go identifier to snake_case, keeping initialisms
+// together: "UserID" becomes "user_id" and "HTTPServer" becomes "http_server".
+func snakeCase(s string) string {
+	r := []rune(s)
+	var sb strings.Builder
+	for i, c := range r {
+		if unicode.IsUpper(c) && i > 0 {
+			prev := r[i-1]
+			nextLower := i+1 < len(r) && unicode.IsLower(r[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				sb.WriteByte('_')
+			}
+		}
+		sb.WriteRune(unicode.ToLower(c))
+	}
+	return sb.String()
+}
+
+// untaggedFields returns the Go name of the fields of struct t whose JSON name
+// is the Go name, mapped to their snake_case name. It recurses into embedded
+// structs.
+func untaggedFields(t reflect.Type, out map[string]string) {
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			for ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				untaggedFields(ft, out)
+				continue
+			}
+		}
+		if name == "" {
+			if _, ok := out[f.Name]; !ok {
+				out[f.Name] = snakeCase(f.Name)
+			}
+		}
+	}
+}
+
+// renameKeysJSON renames the keys in b mapping to untagged struct fields of t,
+// from snake_case to the Go name if toGo is true, from the Go name to
+// snake_case otherwise.
+func renameKeysJSON(b []byte, t reflect.Type, toGo bool) ([]byte, error) {
+	var v any
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	e := json.NewEncoder(buf)
+	e.SetEscapeHTML(false)
+	if err := e.Encode(renameKeys(t, v, toGo)); err != nil {
+		return nil, fmt.Errorf("internal error: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func renameKeys(t reflect.Type, value any, toGo bool) any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if isLeafType(t) {
+		return value
+	}
+	switch v := value.(type) {
+	case map[string]any:
+		switch t.Kind() {
+		case reflect.Struct:
+			untagged := map[string]string{}
+			untaggedFields(t, untagged)
+			rename := untagged
+			if toGo {
+				rename = make(map[string]string, len(untagged))
+				for goName, snake := range untagged {
+					rename[snake] = goName
+				}
+			}
+			fields := collectJSONFields(t)
+			out := make(map[string]any, len(v))
+			for key, vv := range v {
+				newKey := key
+				if n, ok := rename[key]; ok {
+					newKey = n
+				}
+				// Look up the field by its Go side key.
+				goKey := key
+				if toGo {
+					goKey = newKey
+				}
+				if name, ok := fields[goKey]; ok {
+					f, _ := t.FieldByName(name)
+					vv = renameKeys(f.Type, vv, toGo)
+				}
+				out[newKey] = vv
+			}
+			return out
+		case reflect.Map:
+			for key, vv := range v {
+				v[key] = renameKeys(t.Elem(), vv, toGo)
+			}
+		}
+	case []any:
+		if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			for i, vv := range v {
+				v[i] = renameKeys(t.Elem(), vv, toGo)
+			}
+		}
+	}
+	return value
+}
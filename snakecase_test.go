@@ -0,0 +1,65 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSnakeCase(t *testing.T) {
+	for in, want := range map[string]string{
+		"ID":         "id",
+		"UserID":     "user_id",
+		"HTTPServer": "http_server",
+		"Name":       "name",
+		"CreatedAt":  "created_at",
+		"Page2Token": "page2_token",
+	} {
+		if got := snakeCase(in); got != want {
+			t.Errorf("snakeCase(%q)\nwant: %v\ngot:  %v", in, want, got)
+		}
+	}
+}
+
+func TestClient_Post_snake_case(t *testing.T) {
+	t.Parallel()
+	type Owner struct {
+		UserID int
+	}
+	type Repo struct {
+		RepoName  string
+		Tagged    string `json:"TaggedName"`
+		Owner     Owner
+		Labels    map[string]Owner
+		StarCount int `json:",omitempty"`
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		want := `{"TaggedName":"t","labels":{"UserID":{"user_id":2}},"owner":{"user_id":1},"repo_name":"r","star_count":3}` + "\n"
+		if string(b) != want {
+			t.Errorf("Unexpected\nwant: %s\ngot:  %s", want, b)
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_, _ = w.Write(b)
+	}))
+	defer ts.Close()
+
+	c := Client{SnakeCase: true}
+	in := Repo{RepoName: "r", Tagged: "t", Owner: Owner{UserID: 1}, Labels: map[string]Owner{"UserID": {UserID: 2}}, StarCount: 3}
+	var out Repo
+	if err := c.Post(context.Background(), ts.URL, nil, in, &out); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := json.Marshal(out)
+	want, _ := json.Marshal(in)
+	if string(got) != string(want) {
+		t.Errorf("Unexpected\nwant: %s\ngot:  %s", want, got)
+	}
+}
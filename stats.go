@@ -0,0 +1,172 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Stats are cumulative counters of the requests sent by a Client.
+type Stats struct {
+	// Requests is the number of requests sent, by HTTP method.
+	Requests map[string]int64 `json:"requests"`
+	// Responses is the number of responses received, by status class, e.g.
+	// "2xx".
+	Responses map[string]int64 `json:"responses"`
+	// TransportErrors is the number of requests that failed without a
+	// response.
+	TransportErrors int64 `json:"transport_errors"`
+	// BytesSent is the sum of the request bodies' Content-Length.
+	BytesSent int64 `json:"bytes_sent"`
+	// BytesReceived is the number of response body bytes read.
+	BytesReceived int64 `json:"bytes_received"`
+	// DecodeFailures is the number of responses that Get and Post failed to
+	// decode.
+	DecodeFailures int64 `json:"decode_failures"`
+	// Retries is the number of retries done by Client.RetryOn429 and
+	// Client.Retry.
+	Retries int64 `json:"retries"`
+	// Coalesced is the number of calls that shared a request already in
+	// flight instead of sending their own, see Client.Coalesce.
+	Coalesced int64 `json:"coalesced"`
+}
+
+// Stats returns a snapshot of the client's cumulative counters.
+func (c *Client) Stats() Stats {
+	return c.shared().stats.snapshot()
+}
+
+// PublishExpvar publishes the client's Stats as the expvar variable name,
+// making it visible on /debug/vars.
+//
+// It returns an error if name is already registered, instead of panicking
+// like expvar.Publish.
+func (c *Client) PublishExpvar(name string) error {
+	expvarMu.Lock()
+	defer expvarMu.Unlock()
+	if expvar.Get(name) != nil {
+		return fmt.Errorf("expvar %q is already published", name)
+	}
+	expvar.Publish(name, expvar.Func(func() any { return c.Stats() }))
+	return nil
+}
+
+// expvarMu makes checking and publishing an expvar atomic.
+var expvarMu sync.Mutex
+
+// Observer is notified after every request sent by a Client.
+type Observer interface {
+	// ObserveRequest is called once the response headers are received or the
+	// request failed. status is 0 when no response was received. d is the time
+	// to receive the response headers. attempt starts at 1.
+	ObserveRequest(method, host string, status int, d time.Duration, attempt int)
+}
+
+// ObserverFunc adapts a function to the Observer interface.
+type ObserverFunc func(method, host string, status int, d time.Duration, attempt int)
+
+// ObserveRequest implements Observer.
+func (f ObserverFunc) ObserveRequest(method, host string, status int, d time.Duration, attempt int) {
+	f(method, host, status, d, attempt)
+}
+
+type clientStats struct {
+	mu              sync.Mutex
+	requests        map[string]int64
+	responses       map[string]int64
+	transportErrors int64
+	bytesSent       int64
+	bytesReceived   int64
+	decodeFailures  int64
+	retries         int64
+	coalesced       int64
+}
+
+func (s *clientStats) request(req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.requests == nil {
+		s.requests = map[string]int64{}
+	}
+	s.requests[req.Method]++
+	if req.ContentLength > 0 {
+		s.bytesSent += req.ContentLength
+	}
+}
+
+func (s *clientStats) response(resp *http.Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.responses == nil {
+		s.responses = map[string]int64{}
+	}
+	s.responses[strconv.Itoa(resp.StatusCode/100)+"xx"]++
+}
+
+func (s *clientStats) transportError() {
+	s.mu.Lock()
+	s.transportErrors++
+	s.mu.Unlock()
+}
+
+func (s *clientStats) decodeFailure() {
+	s.mu.Lock()
+	s.decodeFailures++
+	s.mu.Unlock()
+}
+
+func (s *clientStats) retry() {
+	s.mu.Lock()
+	s.retries++
+	s.mu.Unlock()
+}
+
+func (s *clientStats) coalesce() {
+	s.mu.Lock()
+	s.coalesced++
+	s.mu.Unlock()
+}
+
+func (s *clientStats) received(n int) {
+	s.mu.Lock()
+	s.bytesReceived += int64(n)
+	s.mu.Unlock()
+}
+
+func (s *clientStats) snapshot() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Stats{
+		Requests:        maps.Clone(s.requests),
+		Responses:       maps.Clone(s.responses),
+		TransportErrors: s.transportErrors,
+		BytesSent:       s.bytesSent,
+		BytesReceived:   s.bytesReceived,
+		DecodeFailures:  s.decodeFailures,
+		Retries:         s.retries,
+		Coalesced:       s.coalesced,
+	}
+}
+
+// countingReader counts the response bytes read.
+type countingReader struct {
+	io.ReadCloser
+	s *clientStats
+}
+
+func (c *countingReader) Read(b []byte) (int, error) {
+	n, err := c.ReadCloser.Read(b)
+	if n > 0 {
+		c.s.received(n)
+	}
+	return n, err
+}
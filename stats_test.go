@@ -0,0 +1,110 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClient_Stats(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+		_, _ = w.Write([]byte(`{"output":"data"}`))
+	}))
+	defer ts.Close()
+
+	c := Client{}
+	ctx := context.Background()
+	var out struct {
+		Output string `json:"output"`
+	}
+	if err := c.Get(ctx, ts.URL, nil, &out); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Post(ctx, ts.URL+"/fail", nil, map[string]string{"input": "data"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(ctx, ts.URL, nil, &map[string]int{}); err == nil {
+		t.Fatal("expected decode error")
+	}
+	if err := c.Get(ctx, "http://127.0.0.1:0", nil, &out); err == nil {
+		t.Fatal("expected transport error")
+	}
+	want := Stats{
+		Requests:        map[string]int64{"GET": 3, "POST": 1},
+		Responses:       map[string]int64{"2xx": 2, "5xx": 1},
+		TransportErrors: 1,
+		BytesSent:       17,
+		BytesReceived:   51,
+		DecodeFailures:  1,
+	}
+	if got := c.Stats(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexpected\nwant: %+v\ngot:  %+v", want, got)
+	}
+
+	// expvar names are global to the process, and tests may run more than
+	// once with -count.
+	name := fmt.Sprintf("httpjson_test_stats_%d", expvarTestID.Add(1))
+	if err := c.PublishExpvar(name); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.PublishExpvar(name); err == nil {
+		t.Error("expected error publishing twice")
+	}
+	var got Stats
+	if err := json.Unmarshal([]byte(expvar.Get(name).String()), &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexpected\nwant: %+v\ngot:  %+v", want, got)
+	}
+}
+
+var expvarTestID atomic.Int64
+
+func TestClient_Observer(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+	type call struct {
+		method, host string
+		status       int
+		attempt      int
+	}
+	var got []call
+	c := Client{Observer: ObserverFunc(func(method, host string, status int, d time.Duration, attempt int) {
+		if d <= 0 {
+			t.Errorf("Unexpected duration %s", d)
+		}
+		got = append(got, call{method, host, status, attempt})
+	})}
+	if err := c.Get(context.Background(), ts.URL, nil, &map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(context.Background(), "http://127.0.0.1:0", nil, &map[string]string{}); err == nil {
+		t.Fatal("expected error")
+	}
+	want := []call{{"GET", ts.Listener.Addr().String(), 404, 1}, {"GET", "127.0.0.1:0", 0, 1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexpected\nwant: %+v\ngot:  %+v", want, got)
+	}
+}
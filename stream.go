@@ -0,0 +1,106 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// canStreamDecode reports whether resp can be decoded straight from its body
+// into out. The options that need the whole body fall back to buffering it.
+func (c *Client) canStreamDecode(resp *http.Response, out any) bool {
+	if !c.StreamDecode || resp.StatusCode >= 400 || c.DumpDir != "" || c.Unmarshal != nil ||
+		c.Relaxed || c.SnakeCase || c.DisallowDuplicateKeys || c.CaseSensitive || c.StrictNumbers || c.DisallowNull {
+		return false
+	}
+	if _, ok := out.(lenientOutput); ok {
+		return false
+	}
+	if t := reflect.TypeOf(out); t != nil && hasFormatTags(t) {
+		return false
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "" {
+		if _, params, err := mime.ParseMediaType(ct); err == nil {
+			switch strings.ToLower(params["charset"]) {
+			case "", "utf-8", "utf8", "us-ascii":
+			default:
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// streamDecode decodes the response body into out without reading it in
+// memory first. The body is not available in the returned *Error.
+func (c *Client) streamDecode(resp *http.Response, out any) error {
+	defer func() {
+		// Drain the trailing whitespace so the connection can be reused.
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+		_ = resp.Body.Close()
+	}()
+	if c.RequireJSON && !isJSONContentType(resp.Header.Get("Content-Type")) {
+		c.shared().stats.decodeFailure()
+		return errors.Join(&ContentTypeError{ContentType: resp.Header.Get("Content-Type")}, newError(resp, nil, false))
+	}
+	br := &bodyReader{r: resp.Body}
+	r := bufio.NewReader(br)
+	if b, _ := r.Peek(len(utf8BOM)); bytes.Equal(b, utf8BOM) {
+		_, _ = r.Discard(len(utf8BOM))
+	}
+	ctx := context.Background()
+	if resp.Request != nil {
+		ctx = resp.Request.Context()
+	}
+	d := json.NewDecoder(r)
+	lenient := c.Lenient
+	if v, ok := ctx.Value(lenientKey{}).(bool); ok {
+		lenient = v
+	}
+	if !lenient {
+		d.DisallowUnknownFields()
+	}
+	d.UseNumber()
+	err := d.Decode(out)
+	if err == io.EOF {
+		if !c.DisallowEmptyBody {
+			return nil
+		}
+		err = ErrEmptyBody
+	} else if errors.Is(br.err, io.ErrUnexpectedEOF) {
+		// The connection was closed early, the JSON is not invalid.
+		err = &TruncatedResponseError{Received: br.n, Expected: resp.ContentLength, Err: br.err}
+	}
+	if err != nil {
+		c.shared().stats.decodeFailure()
+		return errors.Join(err, newError(resp, nil, false))
+	}
+	return nil
+}
+
+// bodyReader records how many bytes were read and the read error, if any.
+type bodyReader struct {
+	r   io.Reader
+	n   int64
+	err error
+}
+
+func (b *bodyReader) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	b.n += int64(n)
+	if err != nil && err != io.EOF {
+		b.err = err
+	}
+	return n, err
+}
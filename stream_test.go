@@ -0,0 +1,75 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClient_StreamDecode(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		switch r.URL.Path {
+		case "/ok":
+			_, _ = w.Write([]byte("\xef\xbb\xbf{\"output\":\"data\"}\n"))
+		case "/extra":
+			_, _ = w.Write([]byte(`{"output":"data","extra":1}`))
+		case "/empty":
+			w.WriteHeader(http.StatusNoContent)
+		case "/invalid":
+			_, _ = w.Write([]byte(`{"output":`))
+		case "/truncated":
+			w.Header().Set("Content-Length", "100")
+			_, _ = w.Write([]byte(`{"output":`))
+			w.(http.Flusher).Flush()
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			_ = conn.Close()
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":"not found"}`))
+		}
+	}))
+	defer ts.Close()
+	c := Client{StreamDecode: true}
+	get := func(path string) (string, error) {
+		var out struct {
+			Output string `json:"output"`
+		}
+		err := c.Get(context.Background(), ts.URL+path, nil, &out)
+		return out.Output, err
+	}
+	if got, err := get("/ok"); err != nil || got != "data" {
+		t.Fatalf("Unexpected %q %v", got, err)
+	}
+	if _, err := get("/empty"); err != nil {
+		t.Fatal(err)
+	}
+	_, err := get("/extra")
+	var herr *Error
+	if !errors.As(err, &herr) || len(herr.ResponseBody) != 0 || !strings.Contains(err.Error(), `json: unknown field "extra"`) {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err = get("/invalid"); err == nil || errors.As(err, new(*TruncatedResponseError)) {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var terr *TruncatedResponseError
+	if _, err = get("/truncated"); !errors.As(err, &terr) || terr.Received != 10 || terr.Expected != 100 {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// Error responses are still buffered.
+	if _, err = get("/missing"); !errors.As(err, &herr) || string(herr.ResponseBody) != `{"error":"not found"}` {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
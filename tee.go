@@ -0,0 +1,39 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"context"
+	"io"
+)
+
+type teeKey struct{}
+
+// TeeResponse returns a context for requests whose response body is copied
+// to w as it is read, for example to save the original payload to disk while
+// it is decoded, without buffering it twice.
+//
+// Only the final response is copied, not the ones discarded by
+// Client.RetryOn429. Nothing is written for Get calls served by
+// Client.Cache. A write error aborts reading the body.
+func TeeResponse(ctx context.Context, w io.Writer) context.Context {
+	return context.WithValue(ctx, teeKey{}, w)
+}
+
+// teeReadCloser writes to w what is read.
+type teeReadCloser struct {
+	io.ReadCloser
+	w io.Writer
+}
+
+func (t *teeReadCloser) Read(p []byte) (int, error) {
+	n, err := t.ReadCloser.Read(p)
+	if n > 0 {
+		if _, werr := t.w.Write(p[:n]); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
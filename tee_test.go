@@ -0,0 +1,36 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTeeResponse(t *testing.T) {
+	t.Parallel()
+	const body = `{"output":"data"}`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_, _ = w.Write([]byte(body))
+	}))
+	defer ts.Close()
+	var buf bytes.Buffer
+	var out struct {
+		Output string `json:"output"`
+	}
+	if err := (&Client{}).Get(TeeResponse(context.Background(), &buf), ts.URL, nil, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Output != "data" {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", "data", out.Output)
+	}
+	if buf.String() != body {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", body, buf.String())
+	}
+}
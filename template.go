@@ -0,0 +1,107 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+// Getf is like Get but expands the {name} placeholders in tmpl with params via
+// ExpandURL first.
+func (c *Client) Getf(ctx context.Context, tmpl string, params any, hdr http.Header, out any) error {
+	u, err := ExpandURL(tmpl, params)
+	if err != nil {
+		return err
+	}
+	return c.Get(ctx, u, hdr, out)
+}
+
+// ExpandURL replaces each {name} placeholder in tmpl with the path escaped
+// value of the parameter name.
+//
+// params is either a map with string keys or a struct, in which case the
+// parameter name is the field's json tag name, or its Go name if there is no
+// tag. It is an error for a placeholder to have no matching parameter.
+//
+// Values are escaped with url.PathEscape, so "/" in a value such as "a/b" or
+// "../admin" stays inside its path segment. The values "." and "..", which
+// PathEscape leaves as is and which would be resolved as dot segments, are
+// rejected. Use it instead of string concatenation so a value cannot change
+// the path.
+func ExpandURL(tmpl string, params any) (string, error) {
+	values, err := urlParams(params)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	for rest := tmpl; ; {
+		start := strings.IndexByte(rest, '{')
+		if start == -1 {
+			sb.WriteString(rest)
+			return sb.String(), nil
+		}
+		end := strings.IndexByte(rest[start:], '}')
+		if end == -1 {
+			return "", fmt.Errorf("unterminated placeholder in %q", tmpl)
+		}
+		name := rest[start+1 : start+end]
+		v, ok := values[name]
+		if !ok {
+			return "", fmt.Errorf("missing url parameter %q", name)
+		}
+		if v == "." || v == ".." {
+			return "", fmt.Errorf("url parameter %q cannot be %q", name, v)
+		}
+		sb.WriteString(rest[:start])
+		sb.WriteString(url.PathEscape(v))
+		rest = rest[start+end+1:]
+	}
+}
+
+func urlParams(params any) (map[string]string, error) {
+	out := map[string]string{}
+	if params == nil {
+		return out, nil
+	}
+	v := reflect.ValueOf(params)
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return out, nil
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("url parameters map key must be string, got %s", v.Type().Key())
+		}
+		for iter := v.MapRange(); iter.Next(); {
+			out[iter.Key().String()] = fmt.Sprint(iter.Value().Interface())
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for name, goName := range collectJSONFields(t) {
+			f := v.FieldByName(goName)
+			for f.Kind() == reflect.Pointer {
+				if f.IsNil() {
+					break
+				}
+				f = f.Elem()
+			}
+			if f.Kind() != reflect.Pointer {
+				out[name] = fmt.Sprint(f.Interface())
+			}
+		}
+	default:
+		return nil, errors.New("url parameters must be a map or a struct")
+	}
+	return out, nil
+}
@@ -0,0 +1,77 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestExpandURL(t *testing.T) {
+	type params struct {
+		ID   int    `json:"id"`
+		Repo string `json:"repo"`
+		Opt  *string
+	}
+	tests := []struct {
+		name   string
+		tmpl   string
+		params any
+		want   string
+		err    string
+	}{
+		{"none", "https://example.com/users", nil, "https://example.com/users", ""},
+		{"map", "/users/{id}/repos/{repo}", map[string]string{"id": "42", "repo": "a/b"}, "/users/42/repos/a%2Fb", ""},
+		{"map any", "/users/{id}", map[string]any{"id": 42}, "/users/42", ""},
+		{"struct", "/users/{id}/repos/{repo}", params{ID: 42, Repo: "../admin"}, "/users/42/repos/..%2Fadmin", ""},
+		{"struct ptr", "/users/{id}", &params{ID: 1}, "/users/1", ""},
+		{"nil ptr field", "/users/{Opt}", params{}, "", "missing url parameter \"Opt\""},
+		{"missing", "/users/{id}", map[string]string{}, "", "missing url parameter \"id\""},
+		{"dot", "/users/{id}/keys", map[string]string{"id": "."}, "", "url parameter \"id\" cannot be \".\""},
+		{"dot dot", "/users/{id}/keys", map[string]string{"id": ".."}, "", "url parameter \"id\" cannot be \"..\""},
+		{"dots in value", "/files/{name}", map[string]string{"name": "..."}, "/files/...", ""},
+		{"unterminated", "/users/{id", map[string]string{"id": "1"}, "", "unterminated placeholder in \"/users/{id\""},
+		{"bad map", "/", map[int]string{}, "", "url parameters map key must be string, got int"},
+		{"bad type", "/", 42, "", "url parameters must be a map or a struct"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExpandURL(tt.tmpl, tt.params)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("Unexpected\nwant: %v\ngot:  %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Unexpected\nwant: %v\ngot:  %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestClient_Getf(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/users/a%2Fb" {
+			t.Errorf("Unexpected path %q", r.URL.EscapedPath())
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+	c := Client{}
+	if err := c.Getf(context.Background(), ts.URL+"/users/{id}", map[string]string{"id": "a/b"}, nil, &map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Getf(context.Background(), ts.URL+"/users/{id}", nil, nil, &map[string]string{}); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"syscall"
+	"time"
+)
+
+// transportOr returns t, or http.DefaultTransport if t is nil.
+func transportOr(t http.RoundTripper) http.RoundTripper {
+	if t == nil {
+		return http.DefaultTransport
+	}
+	return t
+}
+
+// OverrideHosts makes t connect to other addresses for the hostnames in
+// hosts, like a static /etc/hosts entry.
+//
+// The keys are hostnames as found in the URL. The values are either a host
+// or IP, in which case the port from the URL is kept, or a "host:port". The
+// requests are otherwise unchanged: the Host header and the TLS server name
+// are still the original hostname.
+//
+// It has no effect on requests sent through a proxy.
+func OverrideHosts(t *http.Transport, hosts map[string]string) {
+	dial := t.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	hosts = maps.Clone(hosts)
+	t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if host, port, err := net.SplitHostPort(addr); err == nil {
+			if to, ok := hosts[host]; ok {
+				if _, _, err := net.SplitHostPort(to); err == nil {
+					addr = to
+				} else {
+					addr = net.JoinHostPort(to, port)
+				}
+			}
+		}
+		return dial(ctx, network, addr)
+	}
+}
+
+// NewPinnedTransport returns an http.Transport that, on top of the normal
+// certificate verification, only accepts TLS connections whose verified
+// chain contains a public key in pins.
+//
+// pins are SPKIPin() values. To rotate keys, list both the current and the
+// next key, then remove the old one once it is not served anymore.
+//
+// When report is not nil, the transport is in report-only mode: a mismatch
+// is passed to report as a *PinError and the connection is allowed.
+func NewPinnedTransport(pins []string, report func(error)) *http.Transport {
+	t := NewTransport()
+	pins = slices.Clone(pins)
+	t.TLSClientConfig = &tls.Config{
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			chains := cs.VerifiedChains
+			if len(chains) == 0 {
+				// InsecureSkipVerify was set.
+				chains = [][]*x509.Certificate{cs.PeerCertificates}
+			}
+			for _, chain := range chains {
+				for _, cert := range chain {
+					if slices.Contains(pins, SPKIPin(cert)) {
+						return nil
+					}
+				}
+			}
+			err := &PinError{Host: cs.ServerName}
+			if len(cs.PeerCertificates) != 0 {
+				err.Pin = SPKIPin(cs.PeerCertificates[0])
+			}
+			if report != nil {
+				report(err)
+				return nil
+			}
+			return err
+		},
+	}
+	return t
+}
+
+// SPKIPin returns the base64 encoded SHA-256 hash of the certificate's
+// Subject Public Key Info, as used by NewPinnedTransport.
+//
+// It is the same value as computed by:
+//
+//	openssl x509 -pubkey -noout | openssl pkey -pubin -outform der | openssl dgst -sha256 -binary | base64
+func SPKIPin(cert *x509.Certificate) string {
+	h := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// PinError is returned when a server's certificate chain doesn't contain any
+// of the pinned keys.
+type PinError struct {
+	// Host is the TLS server name.
+	Host string
+	// Pin is the SPKIPin() of the server's leaf certificate.
+	Pin string
+}
+
+func (p *PinError) Error() string {
+	return fmt.Sprintf("certificate for %s doesn't match any pinned key; leaf key is %s", p.Host, p.Pin)
+}
+
+// RestrictDestinations protects t against server-side request forgery when
+// fetching user supplied URLs.
+//
+// When hosts is not empty, only these hostnames can be connected to.
+// Independently, connections to IP addresses that are not public, e.g.
+// loopback, private, link-local or carrier-grade NAT, are refused. The check
+// is done on the IP actually dialed, after DNS resolution, so a public name
+// resolving to a private address is refused too.
+//
+// It replaces t.DialContext and disables t.Proxy, since the proxy address
+// would be checked instead of the destination. It also clears t.DialTLSContext
+// and t.DialTLS, which would bypass the check; t then does the TLS handshake
+// itself using t.TLSClientConfig. Refused connections return a
+// *DestinationError.
+func RestrictDestinations(t *http.Transport, hosts []string) {
+	hosts = slices.Clone(hosts)
+	d := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			ap, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return &DestinationError{Addr: address}
+			}
+			if ip := ap.Addr().Unmap(); !ip.IsGlobalUnicast() || ip.IsPrivate() || isBlocked(ip) {
+				return &DestinationError{Addr: address}
+			}
+			return nil
+		},
+	}
+	t.Proxy = nil
+	t.DialTLSContext = nil
+	t.DialTLS = nil
+	t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if len(hosts) != 0 {
+			host, _, err := net.SplitHostPort(addr)
+			if err != nil || !slices.Contains(hosts, host) {
+				return nil, &DestinationError{Addr: addr}
+			}
+		}
+		return d.DialContext(ctx, network, addr)
+	}
+}
+
+// blocked are the prefixes that are global unicast according to netip but
+// must not be reachable by RestrictDestinations.
+var blocked = []netip.Prefix{
+	// "This network", RFC 791; 0.0.0.0 is often routed to localhost.
+	netip.MustParsePrefix("0.0.0.0/8"),
+	// Carrier-grade NAT shared address space, RFC 6598.
+	netip.MustParsePrefix("100.64.0.0/10"),
+	// NAT64 well-known prefix, RFC 6052, which embeds any IPv4 address.
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+func isBlocked(ip netip.Addr) bool {
+	for _, p := range blocked {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// DestinationError is returned when RestrictDestinations refuses a
+// connection.
+type DestinationError struct {
+	// Addr is the refused "host:port" or "ip:port".
+	Addr string
+}
+
+func (d *DestinationError) Error() string {
+	return fmt.Sprintf("connection to %s is not allowed", d.Addr)
+}
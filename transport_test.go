@@ -0,0 +1,194 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"testing"
+)
+
+func TestOverrideHosts(t *testing.T) {
+	t.Parallel()
+	// The test certificate is valid for example.com.
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_, _ = w.Write([]byte(`{"host":"` + r.Host + `","sni":"` + r.TLS.ServerName + `"}`))
+	}))
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, port, _ := net.SplitHostPort(u.Host)
+	tr := ts.Client().Transport.(*http.Transport).Clone()
+	OverrideHosts(tr, map[string]string{"example.com": "127.0.0.1"})
+	c := Client{Client: &http.Client{Transport: tr}}
+	var out struct {
+		Host string `json:"host"`
+		SNI  string `json:"sni"`
+	}
+	if err := c.Get(context.Background(), "https://example.com:"+port, nil, &out); err != nil {
+		t.Fatal(err)
+	}
+	if want := "example.com:" + port; out.Host != want {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", want, out.Host)
+	}
+	if out.SNI != "example.com" {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", "example.com", out.SNI)
+	}
+
+	// Override with a port.
+	tr = ts.Client().Transport.(*http.Transport).Clone()
+	OverrideHosts(tr, map[string]string{"example.com": u.Host})
+	c = Client{Client: &http.Client{Transport: tr}}
+	if err := c.Get(context.Background(), "https://example.com", nil, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Host != "example.com" {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", "example.com", out.Host)
+	}
+}
+
+func TestNewPinnedTransport(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	// Silence the handshake failure log.
+	ts.Config.ErrorLog = log.New(io.Discard, "", 0)
+	ts.StartTLS()
+	defer ts.Close()
+	pin := SPKIPin(ts.Certificate())
+	roots := ts.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+	get := func(pins []string, report func(error)) error {
+		tr := NewPinnedTransport(pins, report)
+		tr.TLSClientConfig.RootCAs = roots
+		c := Client{Client: &http.Client{Transport: tr}}
+		return c.Get(context.Background(), ts.URL, nil, &struct{}{})
+	}
+	if err := get([]string{"old", pin}, nil); err != nil {
+		t.Fatal(err)
+	}
+	err := get([]string{"other"}, nil)
+	var perr *PinError
+	if !errors.As(err, &perr) {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if perr.Pin != pin {
+		t.Errorf("Unexpected\nwant: %v\ngot:  %v", pin, perr.Pin)
+	}
+	// Report only.
+	var reported []error
+	if err := get([]string{"other"}, func(err error) { reported = append(reported, err) }); err != nil {
+		t.Fatal(err)
+	}
+	if len(reported) != 1 || !errors.As(reported[0], &perr) {
+		t.Errorf("Unexpected report: %v", reported)
+	}
+}
+
+func TestRestrictDestinations(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("unexpected request")
+	}))
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, port, _ := net.SplitHostPort(u.Host)
+	data := []struct {
+		name  string
+		hosts []string
+		url   string
+		addr  string
+	}{
+		{"loopback", nil, ts.URL, u.Host},
+		{"localhost", nil, "http://localhost:" + port, ""},
+		{"not_allowed", []string{"example.com"}, ts.URL, u.Host},
+		{"allowed_but_loopback", []string{"127.0.0.1"}, ts.URL, u.Host},
+	}
+	for _, line := range data {
+		t.Run(line.name, func(t *testing.T) {
+			tr := NewTransport()
+			RestrictDestinations(tr, line.hosts)
+			c := Client{Client: &http.Client{Transport: tr}}
+			err := c.Get(context.Background(), line.url, nil, &struct{}{})
+			var derr *DestinationError
+			if !errors.As(err, &derr) {
+				t.Fatalf("expected DestinationError, got %v", err)
+			}
+			if line.addr != "" && derr.Addr != line.addr {
+				t.Errorf("Unexpected\nwant: %v\ngot:  %v", line.addr, derr.Addr)
+			}
+		})
+	}
+}
+
+func TestRestrictDestinations_ranges(t *testing.T) {
+	t.Parallel()
+	tr := NewTransport()
+	RestrictDestinations(tr, nil)
+	// Exercise the Control function through a dial to non routable
+	// addresses; all must be refused before any packet is sent.
+	for _, addr := range []string{"10.1.2.3:80", "192.168.0.1:80", "169.254.169.254:80", "100.64.0.1:80", "0.0.0.0:80", "0.1.2.3:80"} {
+		_, err := tr.DialContext(context.Background(), "tcp", addr)
+		var derr *DestinationError
+		if !errors.As(err, &derr) {
+			t.Errorf("%s: expected DestinationError, got %v", addr, err)
+		}
+	}
+}
+
+func TestIsBlocked(t *testing.T) {
+	t.Parallel()
+	data := []struct {
+		ip   string
+		want bool
+	}{
+		{"0.1.2.3", true},
+		{"100.64.0.1", true},
+		{"64:ff9b::a00:1", true},
+		{"64:ff9b::7f00:1", true},
+		{"8.8.8.8", false},
+		{"2001:4860:4860::8888", false},
+	}
+	for _, line := range data {
+		if got := isBlocked(netip.MustParseAddr(line.ip)); got != line.want {
+			t.Errorf("%s: Unexpected\nwant: %v\ngot:  %v", line.ip, line.want, got)
+		}
+	}
+}
+
+func TestRestrictDestinations_dialTLS(t *testing.T) {
+	t.Parallel()
+	tr := NewTransport()
+	tr.DialTLSContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return nil, errors.New("bypass")
+	}
+	tr.DialTLS = func(network, addr string) (net.Conn, error) {
+		return nil, errors.New("bypass")
+	}
+	RestrictDestinations(tr, nil)
+	if tr.DialTLSContext != nil || tr.DialTLS != nil {
+		t.Fatal("DialTLSContext and DialTLS must be cleared")
+	}
+	c := Client{Client: &http.Client{Transport: tr}}
+	err := c.Get(context.Background(), "https://127.0.0.1:1/", nil, &struct{}{})
+	var derr *DestinationError
+	if !errors.As(err, &derr) {
+		t.Fatalf("expected DestinationError, got %v", err)
+	}
+}
@@ -0,0 +1,25 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"context"
+)
+
+// Unmarshal decodes data into out with the same strict validation as the
+// Client, so JSON read from files, queues or webhooks gets the same rich
+// errors: one *UnknownFieldError per unknown field, with its JSONPath and
+// offset.
+//
+// Unknown fields are rejected unless WithLenient is passed. The other Client
+// decoding options, like DisallowNull or StrictNumbers, can be set with a
+// custom Option. The options unrelated to decoding are ignored.
+func Unmarshal(data []byte, out any, opts ...Option) error {
+	c, err := New(opts...)
+	if err != nil {
+		return err
+	}
+	return c.decode(context.Background(), data, out)
+}
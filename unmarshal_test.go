@@ -0,0 +1,40 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestUnmarshal(t *testing.T) {
+	t.Parallel()
+	type Out struct {
+		Name string `json:"name"`
+		Age  int    `json:"age"`
+	}
+	data := []byte(`{"name":"a","age":null,"x":1}`)
+	var out Out
+	err := Unmarshal(data, &out)
+	var uerr *UnknownFieldError
+	if !errors.As(err, &uerr) || uerr.Path != "$.x" || uerr.Offset != 23 {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	out = Out{}
+	if err = Unmarshal(data, &out, WithLenient()); err != nil || out.Name != "a" {
+		t.Fatalf("Unexpected %+v %v", out, err)
+	}
+	disallowNull := func(c *Client) error {
+		c.DisallowNull = true
+		return nil
+	}
+	if err = Unmarshal(data, &out, WithLenient(), disallowNull); err == nil {
+		t.Fatal("expected error")
+	}
+	wantErr := errors.New("bad option")
+	if err = Unmarshal(data, &out, func(*Client) error { return wantErr }); err != wantErr {
+		t.Fatalf("Unexpected\nwant: %v\ngot:  %v", wantErr, err)
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// walkJSON calls fn for each value in a generically decoded JSON value along
+// with the Go type it decodes into, then descends into objects and arrays.
+//
+// t is passed to fn as declared, pointers included. Unknown fields and types
+// implementing json.Unmarshaler are not descended into.
+func walkJSON(t reflect.Type, value any, prefix string, fn func(t reflect.Type, value any, prefix string) []error) []error {
+	out := fn(t, value, prefix)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if isLeafType(t) {
+		return out
+	}
+	switch v := value.(type) {
+	case map[string]any:
+		switch t.Kind() {
+		case reflect.Struct:
+			fields := collectJSONFields(t)
+			for key, vv := range v {
+				if name, ok := fields[key]; ok {
+					f, _ := t.FieldByName(name)
+					p := key
+					if prefix != "" {
+						p = prefix + "." + key
+					}
+					out = append(out, walkJSON(f.Type, vv, p, fn)...)
+				}
+			}
+		case reflect.Map:
+			for key, vv := range v {
+				out = append(out, walkJSON(t.Elem(), vv, prefix+fmt.Sprintf("[%s]", key), fn)...)
+			}
+		}
+	case []any:
+		if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			for i, vv := range v {
+				out = append(out, walkJSON(t.Elem(), vv, prefix+fmt.Sprintf("[%d]", i), fn)...)
+			}
+		}
+	}
+	return out
+}
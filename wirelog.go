@@ -0,0 +1,91 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+)
+
+// WireLogBodyLimit is the maximum number of bytes of the response body
+// included in each Client.WireLog line.
+var WireLogBodyLimit = 1024
+
+// WireLogEntry is one line written to Client.WireLog.
+type WireLogEntry struct {
+	Time   time.Time `json:"time"`
+	Method string    `json:"method"`
+	// URL has its secrets redacted.
+	URL    string `json:"url"`
+	Status int    `json:"status,omitempty"`
+	// Duration is the time until the response headers were received.
+	Duration time.Duration `json:"duration_ns"`
+	// Total is the time until the response body was closed.
+	Total         time.Duration `json:"total_ns"`
+	BytesSent     int64         `json:"bytes_sent"`
+	BytesReceived int64         `json:"bytes_received"`
+	// Body is the start of the response body, up to WireLogBodyLimit bytes.
+	Body      string `json:"body,omitempty"`
+	Truncated bool   `json:"truncated,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// writeWireLog appends e as a JSON line to c.WireLog.
+func (c *Client) writeWireLog(e *WireLogEntry) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	b = append(b, '\n')
+	s := c.shared()
+	s.wireMu.Lock()
+	defer s.wireMu.Unlock()
+	_, _ = c.WireLog.Write(b)
+}
+
+func newWireLogEntry(req *http.Request, start time.Time) *WireLogEntry {
+	return &WireLogEntry{
+		Time:      start.UTC(),
+		Method:    req.Method,
+		URL:       redactURL(req.URL),
+		BytesSent: max(req.ContentLength, 0),
+	}
+}
+
+// wireLogReader records the response body and writes the entry on Close.
+type wireLogReader struct {
+	io.ReadCloser
+	c     *Client
+	e     *WireLogEntry
+	start time.Time
+	body  []byte
+	done  bool
+}
+
+func (w *wireLogReader) Read(p []byte) (int, error) {
+	n, err := w.ReadCloser.Read(p)
+	w.e.BytesReceived += int64(n)
+	if room := WireLogBodyLimit - len(w.body); room > 0 {
+		w.body = append(w.body, p[:min(n, room)]...)
+	}
+	if err != nil && err != io.EOF && w.e.Error == "" {
+		w.e.Error = err.Error()
+	}
+	return n, err
+}
+
+func (w *wireLogReader) Close() error {
+	err := w.ReadCloser.Close()
+	if !w.done {
+		w.done = true
+		w.e.Total = time.Since(w.start)
+		w.e.Body = string(w.body)
+		w.e.Truncated = w.e.BytesReceived > int64(len(w.body))
+		w.c.writeWireLog(w.e)
+	}
+	return err
+}
@@ -0,0 +1,67 @@
+// Copyright 2025 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package httpjson
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClient_WireLog(t *testing.T) {
+	t.Parallel()
+	long := strings.Repeat("a", 2000)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if r.URL.Path == "/long" {
+			_, _ = w.Write([]byte(`{"output":"` + long + `"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"output":"data"}`))
+	}))
+	var buf bytes.Buffer
+	c := Client{WireLog: &buf}
+	var out struct {
+		Output string `json:"output"`
+	}
+	if err := c.Post(context.Background(), ts.URL+"/?api_key=secret", nil, map[string]string{"in": "x"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(context.Background(), ts.URL+"/long", nil, &out); err != nil {
+		t.Fatal(err)
+	}
+	ts.Close()
+	if err := c.Get(context.Background(), ts.URL, nil, &out); err == nil {
+		t.Fatal("expected error")
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Unexpected lines: %q", lines)
+	}
+	var entries []WireLogEntry
+	for _, l := range lines {
+		var e WireLogEntry
+		if err := json.Unmarshal([]byte(l), &e); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, e)
+	}
+	e := entries[0]
+	if e.Method != "POST" || e.URL != ts.URL+"/?api_key=REDACTED" || e.Status != 200 || e.BytesSent != 11 || e.BytesReceived != 17 || e.Body != `{"output":"data"}` || e.Truncated {
+		t.Errorf("Unexpected %+v", e)
+	}
+	e = entries[1]
+	if e.BytesReceived != int64(len(long)+13) || len(e.Body) != WireLogBodyLimit || !e.Truncated {
+		t.Errorf("Unexpected %+v", e)
+	}
+	if e = entries[2]; e.Error == "" || e.Status != 0 {
+		t.Errorf("Unexpected %+v", e)
+	}
+}